@@ -6,15 +6,24 @@
 package rtsp
 
 import (
+	"net/http"
+
 	"github.com/rsfreitas/go-rtsp/internal/packet"
+	"github.com/rsfreitas/go-rtsp/internal/sdp"
 )
 
 type announceMethod struct {
 	clientHandler ClientAnnounce
+
+	Streams *streamRegistry
+	Sources *sourceRegistry
+	Conn    *conn
 }
 
+// Verify requires a ClientAnnounce handler, so that a server only accepts
+// published streams when its application asks for them.
 func (a *announceMethod) Verify(p *packet.Packet, handler interface{}) error {
-	if m, ok := handler.(interface{ Announce() }); ok {
+	if m, ok := handler.(ClientAnnounce); ok {
 		a.clientHandler = m
 	} else {
 		return &methodError{"client method not implemented", true}
@@ -23,10 +32,55 @@ func (a *announceMethod) Verify(p *packet.Packet, handler interface{}) error {
 	return nil
 }
 
-func (a *announceMethod) Handle(p *packet.Packet) {
-	if a.clientHandler != nil {
-		a.clientHandler.Announce()
+func (a *announceMethod) Handle(p *packet.Packet, r *Request) {
+	w := newResponse(p)
+
+	if r.Header.Get("Content-Type") != "application/sdp" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	desc, err := sdp.Decode(p.Request.Body)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// A stream can't be replaced while it is available, and paths of
+	// sources belong to their upstream servers even before being opened.
+	path := cleanPath(p.Request.URL.Path)
+
+	if _, ok := a.Streams.get(path); ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if _, ok := a.Sources.get(path); ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	stream, err := newAnnouncedStream(path, desc, a.Conn)
+
+	if err != nil {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	a.clientHandler.Announce(w, r)
+
+	if w.failed() {
+		return
 	}
+
+	if err := a.Streams.add(stream); err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	a.Conn.addStream(stream)
+	w.finish()
 }
 
 func (a *announceMethod) Type() methodType {
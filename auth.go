@@ -5,6 +5,17 @@
 //
 package rtsp
 
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rsfreitas/go-rtsp/internal/auth"
+	"github.com/rsfreitas/go-rtsp/internal/header"
+	"github.com/rsfreitas/go-rtsp/internal/packet"
+)
+
 type AuthorizationType int
 
 const (
@@ -12,3 +23,196 @@ const (
 	AuthorizationBasic
 	AuthorizationDigest
 )
+
+const (
+	defaultRealm         = "go-rtsp"
+	defaultNonceLifetime = 5 * time.Minute
+)
+
+// Authenticator is the interface that gives the server access to the users
+// credentials, so it can validate clients requests.
+type Authenticator interface {
+	// Password gives the password of a user. It must return false if the
+	// user is unknown.
+	Password(username string) (string, bool)
+}
+
+// userAuthenticator is an Authenticator with a single user.
+type userAuthenticator struct {
+	username string
+	password string
+}
+
+func (u *userAuthenticator) Password(username string) (string, bool) {
+	if username != u.username {
+		return "", false
+	}
+
+	return u.password, true
+}
+
+// authorization holds everything required to enforce the clients
+// authentication.
+type authorization struct {
+	authType      AuthorizationType
+	realm         string
+	authenticator Authenticator
+	nonces        *auth.NonceStore
+}
+
+// enabled tells if clients must be authenticated.
+func (a *authorization) enabled() bool {
+	return a.authType == AuthorizationBasic || a.authType == AuthorizationDigest
+}
+
+// authorize validates the request credentials. If they're missing or are
+// invalid, the response is set as 401 with a new challenge and false is
+// returned.
+func (a *authorization) authorize(p *packet.Packet) bool {
+	if !a.enabled() {
+		return true
+	}
+
+	var stale bool
+
+	if field, ok := p.Request.Headers["Authorization"]; ok {
+		credentials, err := header.NewAuthorizationFromString(field[0])
+
+		if err == nil {
+			var valid bool
+			valid, stale = a.validate(p, credentials)
+
+			if valid {
+				return true
+			}
+		}
+	}
+
+	challenge := &header.Authenticate{
+		Realm: a.realm,
+	}
+
+	switch a.authType {
+	case AuthorizationBasic:
+		challenge.Scheme = header.AuthorizationSchemeBasic
+
+	case AuthorizationDigest:
+		nonce, err := a.nonces.New()
+
+		if err != nil {
+			p.Response.StatusCode = http.StatusInternalServerError
+			p.Response.StatusText = http.StatusText(p.Response.StatusCode)
+			return false
+		}
+
+		challenge.Scheme = header.AuthorizationSchemeDigest
+		challenge.Nonce = nonce
+		challenge.Algorithm = "MD5"
+		challenge.Qop = "auth"
+		challenge.Stale = stale
+	}
+
+	p.Response.StatusCode = http.StatusUnauthorized
+	p.Response.StatusText = http.StatusText(p.Response.StatusCode)
+	p.Response.Headers.Set("WWW-Authenticate", challenge.String())
+
+	return false
+}
+
+// validate checks if the credentials sent by the client are valid. It also
+// tells if they were refused only because the nonce has expired.
+func (a *authorization) validate(p *packet.Packet, credentials *header.Authorization) (bool, bool) {
+	password, ok := a.authenticator.Password(credentials.Username)
+
+	if !ok {
+		return false, false
+	}
+
+	switch a.authType {
+	case AuthorizationBasic:
+		if credentials.Scheme != header.AuthorizationSchemeBasic {
+			return false, false
+		}
+
+		return subtle.ConstantTimeCompare([]byte(credentials.Password), []byte(password)) == 1, false
+
+	case AuthorizationDigest:
+		if credentials.Scheme != header.AuthorizationSchemeDigest ||
+			credentials.Realm != a.realm {
+			return false, false
+		}
+
+		if credentials.Algorithm != "" && !strings.EqualFold(credentials.Algorithm, "MD5") {
+			return false, false
+		}
+
+		if !sameURI(p, credentials.URI) {
+			return false, false
+		}
+
+		// Clients answering without qop (RFC 2069) send no nonce count,
+		// so their nonce can be used only once.
+		if credentials.Qop == "" {
+			if credentials.Nc != "" || credentials.Cnonce != "" {
+				return false, false
+			}
+		} else if credentials.Qop != "auth" || credentials.Nc == "" || credentials.Cnonce == "" {
+			return false, false
+		}
+
+		ha1 := auth.HA1(credentials.Username, a.realm, password)
+		ha2 := auth.HA2(p.Request.Method, credentials.URI)
+		expected := auth.Response(ha1, ha2, credentials.Nonce, credentials.Nc,
+			credentials.Cnonce, credentials.Qop)
+
+		if subtle.ConstantTimeCompare([]byte(credentials.Response), []byte(expected)) != 1 {
+			return false, false
+		}
+
+		// The nonce is only checked after the response, so its count is not
+		// changed by requests with invalid credentials.
+		switch a.nonces.Validate(credentials.Nonce, credentials.Nc) {
+		case auth.NonceValid:
+			return true, false
+
+		case auth.NonceExpired, auth.NonceReplayed:
+			// The credentials are right, so the client only needs a
+			// new nonce.
+			return false, true
+		}
+	}
+
+	return false, false
+}
+
+// sameURI checks if the URI used by the client to compute its Digest
+// response is the one from the request.
+func sameURI(p *packet.Packet, uri string) bool {
+	if p.Request.URL == nil {
+		return false
+	}
+
+	return uri == p.Request.URL.String() || uri == p.Request.URL.RequestURI()
+}
+
+func newAuthorization(options ServerSetup) *authorization {
+	a := &authorization{
+		authType:      options.AuthType,
+		realm:         options.Realm,
+		authenticator: options.Authenticator,
+		nonces:        auth.NewNonceStore(defaultNonceLifetime),
+	}
+
+	if a.realm == "" {
+		a.realm = defaultRealm
+	}
+
+	if a.authenticator == nil {
+		a.authenticator = &userAuthenticator{
+			username: options.Username,
+			password: options.Password,
+		}
+	}
+
+	return a
+}
@@ -5,30 +5,47 @@
 //
 package rtsp
 
+// ClientPlay is implemented by handlers that support the PLAY method.
 type ClientPlay interface {
-	Play()
+	Play(w ResponseWriter, r *Request)
 }
 
+// ClientPause is implemented by handlers that support the PAUSE method.
 type ClientPause interface {
-	Pause()
+	Pause(w ResponseWriter, r *Request)
 }
 
+// ClientTeardown is implemented by handlers that want to be notified about
+// the TEARDOWN method.
 type ClientTeardown interface {
-	Teardown()
+	Teardown(w ResponseWriter, r *Request)
 }
 
+// ClientRecord is implemented by handlers that support the RECORD method,
+// which starts the publishing of an announced stream. Replying with an error
+// refuses it. Servers whose handler doesn't implement it reply RECORD with
+// 405 Method Not Allowed, since publishing must be explicitly enabled.
 type ClientRecord interface {
-	Record()
+	Record(w ResponseWriter, r *Request)
 }
 
+// ClientAnnounce is implemented by handlers that accept streams published
+// by clients. The announced stream is made available at the request URL
+// path, unless the handler replies with an error, and it is removed when
+// its publisher leaves. Servers whose handler doesn't implement it reply
+// ANNOUNCE with 405 Method Not Allowed.
 type ClientAnnounce interface {
-	Announce()
+	Announce(w ResponseWriter, r *Request)
 }
 
+// ClientGetParameter is implemented by handlers that support the
+// GET_PARAMETER method.
 type ClientGetParameter interface {
-	GetParameter()
+	GetParameter(w ResponseWriter, r *Request)
 }
 
+// ClientSetParameter is implemented by handlers that support the
+// SET_PARAMETER method.
 type ClientSetParameter interface {
-	SetParameter()
+	SetParameter(w ResponseWriter, r *Request)
 }
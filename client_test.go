@@ -0,0 +1,173 @@
+//
+// Description: RTSP client tests.
+//
+package rtsp_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rsfreitas/go-rtsp"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testSPS = []byte{0x67, 0x42, 0xc0, 0x1f, 0xda, 0x01, 0x40, 0x16, 0xe8}
+	testPPS = []byte{0x68, 0xce, 0x3c, 0x80}
+)
+
+type playHandler struct{}
+
+func (playHandler) Play(w rtsp.ResponseWriter, r *rtsp.Request) {}
+
+// startServer creates a server listening on an ephemeral local port, giving
+// the URL of its stream.
+func startServer(t *testing.T, options rtsp.ServerSetup, handler interface{}) (*rtsp.Server, *rtsp.Stream, string) {
+	options.ListenAddrs = []string{"127.0.0.1:0"}
+	s, err := rtsp.NewServer(options, handler)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Start()
+
+	stream, err := s.AddStream("/cam", &rtsp.MediaSetup{SPS: testSPS, PPS: testPPS})
+
+	if err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+
+	return s, stream, "rtsp://" + s.Addrs()[0].String() + "/cam"
+}
+
+// testFrame gives a H.264 IDR frame large enough to be fragmented.
+func testFrame() []byte {
+	frame := make([]byte, 3000)
+	frame[0] = 0x65
+
+	for i := 1; i < len(frame); i++ {
+		frame[i] = byte(i)
+	}
+
+	return frame
+}
+
+// receiveFrame writes frames until the client receives one.
+func receiveFrame(write func(time.Duration, []byte) error, frames chan *rtsp.Frame) *rtsp.Frame {
+	ticker := time.NewTicker(40 * time.Millisecond)
+	defer ticker.Stop()
+
+	timeout := time.After(5 * time.Second)
+
+	for i := 0; ; i++ {
+		select {
+		case f := <-frames:
+			return f
+
+		case <-ticker.C:
+			write(time.Duration(i)*40*time.Millisecond, testFrame())
+
+		case <-timeout:
+			return nil
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	for _, transport := range []rtsp.ClientTransport{rtsp.ClientTransportUDP, rtsp.ClientTransportTCP} {
+		assert := assert.New(t)
+		s, stream, url := startServer(t, rtsp.ServerSetup{UDPPortMin: 47000, UDPPortMax: 47099}, playHandler{})
+		frames := make(chan *rtsp.Frame, 64)
+
+		c, err := rtsp.Dial(url, rtsp.ClientSetup{
+			Transport:  transport,
+			UDPPortMin: 47100,
+			UDPPortMax: 47199,
+			Receive: func(f *rtsp.Frame) {
+				select {
+				case frames <- f:
+				default:
+				}
+			},
+		})
+
+		if !assert.Nil(err) {
+			s.Close()
+			continue
+		}
+
+		tracks, err := c.Describe()
+		assert.Nil(err)
+
+		if assert.Len(tracks, 1) {
+			assert.Equal("video", tracks[0].Type)
+			assert.Equal("H264/90000", tracks[0].Encoding)
+			assert.Nil(c.Setup(tracks[0]))
+		}
+
+		assert.Nil(c.Play(nil))
+
+		if f := receiveFrame(stream.WriteSample, frames); assert.NotNil(f) {
+			assert.Equal(tracks[0], f.Track)
+			assert.True(bytes.Contains(f.Data, testFrame()))
+		}
+
+		sessions := s.Sessions()
+
+		if assert.Len(sessions, 1) {
+			assert.Equal(rtsp.SessionPlaying, sessions[0].State)
+		}
+
+		assert.Nil(c.Pause())
+
+		if info, ok := s.Session(sessions[0].ID); assert.True(ok) {
+			assert.Equal(rtsp.SessionReady, info.State)
+		}
+
+		assert.Nil(c.Teardown())
+		assert.Len(s.Sessions(), 0)
+
+		c.Close()
+		s.Close()
+	}
+}
+
+func TestClientDigest(t *testing.T) {
+	assert := assert.New(t)
+	s, _, url := startServer(t, rtsp.ServerSetup{
+		UDPPortMin: 47200,
+		UDPPortMax: 47299,
+		AuthType:   rtsp.AuthorizationDigest,
+		Username:   "user",
+		Password:   "secret",
+	}, playHandler{})
+
+	defer s.Close()
+
+	// The client answers the 401 challenge with the credentials
+	c, err := rtsp.Dial(url, rtsp.ClientSetup{Username: "user", Password: "secret"})
+	assert.Nil(err)
+
+	tracks, err := c.Describe()
+	assert.Nil(err)
+	assert.Len(tracks, 1)
+
+	// Following requests are authorized as well
+	_, err = c.Options()
+	assert.Nil(err)
+	c.Close()
+
+	c, err = rtsp.Dial(url, rtsp.ClientSetup{Username: "user", Password: "wrong"})
+	assert.Nil(err)
+
+	_, err = c.Describe()
+
+	if e, ok := err.(*rtsp.ResponseError); assert.True(ok) {
+		assert.Equal(401, e.StatusCode)
+	}
+
+	c.Close()
+}
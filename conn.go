@@ -0,0 +1,271 @@
+//
+// Description: A client connection.
+//
+package rtsp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"sync"
+
+	"github.com/rsfreitas/go-rtsp/internal/packet"
+	"github.com/rsfreitas/go-rtsp/internal/rtp"
+	"github.com/rsfreitas/go-rtsp/internal/srtp"
+)
+
+// conn holds a client RTSP connection. Since RTP and RTCP data may be
+// transferred through it, as interleaved frames, writes must be serialized
+// and received frames must be dispatched to their sessions.
+type conn struct {
+	net.Conn
+
+	reader    *bufio.Reader
+	writeLock sync.Mutex
+	lock      sync.RWMutex
+	channels  map[int]*rtp.Session
+	sessions  map[string]*session
+
+	// streams announced through the connection, which are gone when it
+	// is closed.
+	streams []*Stream
+
+	// srtpKeys holds the SRTP master keys of the tracks described to the
+	// client, which protect their media.
+	srtpKeys map[*Track][]byte
+}
+
+// Write writes data to the connection, without mixing it with other
+// goroutines writes.
+func (c *conn) Write(b []byte) (int, error) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	return c.Conn.Write(b)
+}
+
+// WriteInterleaved sends payload as an interleaved frame using a specific
+// channel.
+func (c *conn) WriteInterleaved(channel int, payload []byte) error {
+	b, err := packet.MarshalInterleavedFrame(channel, payload)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Write(b)
+	return err
+}
+
+// channelsAvailable checks if interleaved channels are not being used by
+// another session from the connection. Channels used by replaced, the
+// session about to be replaced, are available.
+func (c *conn) channelsAvailable(channels []int, replaced *rtp.Session) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, ch := range channels {
+		if r, ok := c.channels[ch]; ok && (replaced == nil || r != replaced) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// addTrack registers the interleaved RTP session of a client session track,
+// so its received frames can be dispatched to it.
+func (c *conn) addTrack(sess *session, r *rtp.Session) {
+	c.addChannels(r)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.sessions[sess.id] = sess
+}
+
+// addChannels registers the interleaved channels of a RTP session, so its
+// received frames can be dispatched to it.
+func (c *conn) addChannels(r *rtp.Session) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, ch := range r.Interleaved() {
+		c.channels[ch] = r
+	}
+}
+
+// removeTrack unregisters the interleaved channels of a RTP session.
+func (c *conn) removeTrack(r *rtp.Session) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, ch := range r.Interleaved() {
+		if c.channels[ch] == r {
+			delete(c.channels, ch)
+		}
+	}
+}
+
+// removeSession unregisters all interleaved tracks of a client session from
+// the connection.
+func (c *conn) removeSession(sess *session) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, r := range sess.rtpSessions() {
+		for _, ch := range r.Interleaved() {
+			if c.channels[ch] == r {
+				delete(c.channels, ch)
+			}
+		}
+	}
+
+	delete(c.sessions, sess.id)
+}
+
+// detachSessions removes all client sessions with interleaved tracks from
+// the connection, returning them. Since they can't outlive the connection
+// they must be closed by the caller.
+func (c *conn) detachSessions() []*session {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var sessions []*session
+
+	for _, sess := range c.sessions {
+		sessions = append(sessions, sess)
+	}
+
+	c.channels = make(map[int]*rtp.Session)
+	c.sessions = make(map[string]*session)
+
+	return sessions
+}
+
+// busy tells if the connection has sessions with interleaved tracks, or
+// streams announced through it.
+func (c *conn) busy() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return len(c.sessions) > 0 || len(c.streams) > 0
+}
+
+// addStream registers a stream announced by the client.
+func (c *conn) addStream(s *Stream) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.streams = append(c.streams, s)
+}
+
+// detachStreams removes all streams announced by the client from the
+// connection, returning them to be removed by the caller.
+func (c *conn) detachStreams() []*Stream {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	streams := c.streams
+	c.streams = nil
+
+	return streams
+}
+
+// secure tells if the connection uses TLS (RTSPS), so the client may
+// receive secrets through it.
+func (c *conn) secure() bool {
+	_, ok := c.Conn.(*tls.Conn)
+	return ok
+}
+
+// trackKeys gives the SRTP master keys of all tracks of a stream, creating
+// the ones not described to the client yet.
+func (c *conn) trackKeys(s *Stream) ([][]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var keys [][]byte
+
+	for _, t := range s.tracks {
+		key, ok := c.srtpKeys[t]
+
+		if !ok {
+			var err error
+
+			if key, err = srtp.GenerateKey(); err != nil {
+				return nil, err
+			}
+
+			c.srtpKeys[t] = key
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// trackKey gives the SRTP master key of a track, if it was described to the
+// client.
+func (c *conn) trackKey(t *Track) ([]byte, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	key, ok := c.srtpKeys[t]
+
+	return key, ok
+}
+
+// dispatchInterleaved delivers an interleaved frame payload to the session
+// that owns its channel. Frames from unknown channels are discarded.
+func (c *conn) dispatchInterleaved(channel int, payload []byte) {
+	c.lock.RLock()
+	session, ok := c.channels[channel]
+	c.lock.RUnlock()
+
+	if ok {
+		session.HandleInterleaved(channel, payload)
+	}
+}
+
+// hostAddr gives the IP address of a network address, with its IPv6 zone,
+// if any.
+func hostAddr(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+
+	if err != nil {
+		return addr.String()
+	}
+
+	return host
+}
+
+// advertisedAddr gives the server address told to the client, such as the
+// source of its media. It is the public address (or host name), unless it
+// isn't of the same family of the address the client connected to, when
+// the connection one is used.
+func (c *conn) advertisedAddr(public string) string {
+	local, _ := rtp.ParseAddr(hostAddr(c.LocalAddr()))
+	ip := net.ParseIP(public)
+
+	if local == nil || (public != "" && ip == nil) {
+		return public
+	}
+
+	if ip != nil && (ip.To4() == nil) == (local.To4() == nil) {
+		return public
+	}
+
+	return local.String()
+}
+
+func newConn(c net.Conn) *conn {
+	return &conn{
+		Conn:     c,
+		reader:   bufio.NewReaderSize(c, defaultRequestBufferSize),
+		channels: make(map[int]*rtp.Session),
+		sessions: make(map[string]*session),
+		srtpKeys: make(map[*Track][]byte),
+	}
+}
@@ -8,13 +8,27 @@ package rtsp
 import (
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/rsfreitas/go-rtsp/internal/packet"
-	"github.com/rsfreitas/go-rtsp/internal/sdp"
 )
 
 type describeMethod struct {
-	Session *sdp.Session
+	Streams *streamRegistry
+	Conn    *conn
+
+	// PublicAddr, if set, is the server address announced inside the
+	// session description.
+	PublicAddr string
+
+	// SRTP announces the SRTP keys of the stream tracks to clients
+	// connected through TLS.
+	SRTP bool
+
+	// OpenSource gives the stream of a source, connecting to its upstream
+	// server, when the URL doesn't refer to an available stream.
+	OpenSource func(u *url.URL) (*Stream, error)
 }
 
 func (d *describeMethod) Verify(p *packet.Packet, handler interface{}) error {
@@ -36,18 +50,54 @@ func (d *describeMethod) isAcceptable(p *packet.Packet) bool {
 	return false
 }
 
-func (d *describeMethod) Handle(p *packet.Packet) {
+func (d *describeMethod) Handle(p *packet.Packet, r *Request) {
 	if !d.isAcceptable(p) {
 		p.Response.StatusCode = http.StatusNotAcceptable
 		p.Response.StatusText = http.StatusText(http.StatusNotAcceptable)
 		return
 	}
 
+	stream, control := d.Streams.lookup(p.Request.URL)
+
+	// Sources are only found at their exact path, which may be below the
+	// path of a stream, such as a source at /cam1 with a stream at /.
+	if (stream == nil || control != "") && d.OpenSource != nil {
+		source, err := d.OpenSource(p.Request.URL)
+
+		if err != nil {
+			p.Response.StatusCode = http.StatusBadGateway
+			p.Response.StatusText = http.StatusText(http.StatusBadGateway)
+			return
+		}
+
+		if source != nil {
+			stream, control = source, ""
+		}
+	}
+
+	if stream == nil || control != "" {
+		p.Response.StatusCode = http.StatusNotFound
+		p.Response.StatusText = http.StatusText(http.StatusNotFound)
+		return
+	}
+
+	// Keys are only sent through connections nobody else can read
+	var keys [][]byte
+
+	if d.SRTP && d.Conn.secure() {
+		var err error
+
+		if keys, err = d.Conn.trackKeys(stream); err != nil {
+			p.Response.StatusCode = http.StatusInternalServerError
+			p.Response.StatusText = http.StatusText(http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// We send the SDP representation of options used when creating the
-	// server
-	p.Response.Body = d.Session.Bytes()
-	//	var b []byte
-	//	p.Response.Body = d.Session.AppendTo(b)
+	// stream
+	p.Response.Body = stream.describe(d.Conn.advertisedAddr(d.PublicAddr), keys).Bytes()
+	p.Response.Headers.Add("Content-Base", contentBase(p.Request.URL))
 
 	p.Response.StatusCode = http.StatusOK
 	p.Response.StatusText = http.StatusText(http.StatusOK)
@@ -56,6 +106,18 @@ func (d *describeMethod) Handle(p *packet.Packet) {
 		fmt.Sprintf("%d", len(string(p.Response.Body))))
 }
 
+// contentBase gives the base URL clients must use to resolve relative
+// track URLs.
+func contentBase(u *url.URL) string {
+	s := u.String()
+
+	if !strings.HasSuffix(s, "/") {
+		s += "/"
+	}
+
+	return s
+}
+
 func (d *describeMethod) Type() methodType {
 	return methodDescribe
 }
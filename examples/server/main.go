@@ -16,30 +16,28 @@ import (
 
 type Handler struct{}
 
-//func (h *Handler) Setup(w *rtsp.Response, r *rtsp.Request) {
-//}
-
-func (h *Handler) Play() {
-	fmt.Println("Client Play")
+func (h *Handler) Play(w rtsp.ResponseWriter, r *rtsp.Request) {
+	fmt.Println("Client Play", r.URL, r.Session)
 }
 
-func (h *Handler) Pause() {
-	fmt.Println("Client Pause")
+func (h *Handler) Pause(w rtsp.ResponseWriter, r *rtsp.Request) {
+	fmt.Println("Client Pause", r.URL, r.Session)
 }
 
-/*func (h *Handler) Record(w *rtsp.Response, r *rtsp.Request) {
+func (h *Handler) Teardown(w rtsp.ResponseWriter, r *rtsp.Request) {
+	fmt.Println("Client Teardown", r.URL, r.Session)
 }
 
-func (h *Handler) Announce(w *rtsp.Response, r *rtsp.Request) {
+/*func (h *Handler) Record(w rtsp.ResponseWriter, r *rtsp.Request) {
 }
 
-func (h *Handler) Teardown(w *rtsp.Response, r *rtsp.Request) {
+func (h *Handler) Announce(w rtsp.ResponseWriter, r *rtsp.Request) {
 }
 
-func (h *Handler) SetParameter(w *rtsp.Response, r *rtsp.Request) {
+func (h *Handler) SetParameter(w rtsp.ResponseWriter, r *rtsp.Request) {
 }
 
-func (h *Handler) GetParameter(w *rtsp.Response, r *rtsp.Request) {
+func (h *Handler) GetParameter(w rtsp.ResponseWriter, r *rtsp.Request) {
 }*/
 
 func monitorOurself(server *rtsp.Server) {
@@ -79,6 +77,15 @@ func main() {
 		os.Exit(-1)
 	}
 
+	// Other streams may be added (or removed) at any time
+	if _, err := server.AddStream("/cam2", &rtsp.MediaSetup{
+		Port:       8003,
+		ClientHost: "127.0.0.1",
+	}); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+
 	go monitorOurself(server)
 	fmt.Println("Starting server")
 	server.Start()
@@ -14,7 +14,7 @@ type getParameterMethod struct {
 }
 
 func (g *getParameterMethod) Verify(p *packet.Packet, handler interface{}) error {
-	if m, ok := handler.(interface{ GetParameter() }); ok {
+	if m, ok := handler.(ClientGetParameter); ok {
 		g.clientHandler = m
 	} else {
 		return &methodError{"client method not implemented", true}
@@ -23,10 +23,14 @@ func (g *getParameterMethod) Verify(p *packet.Packet, handler interface{}) error
 	return nil
 }
 
-func (g *getParameterMethod) Handle(p *packet.Packet) {
+func (g *getParameterMethod) Handle(p *packet.Packet, r *Request) {
+	w := newResponse(p)
+
 	if g.clientHandler != nil {
-		g.clientHandler.GetParameter()
+		g.clientHandler.GetParameter(w, r)
 	}
+
+	w.finish()
 }
 
 func (g *getParameterMethod) Type() methodType {
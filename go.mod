@@ -7,5 +7,4 @@ require (
 	github.com/gortc/sdp v0.15.0
 	github.com/pkg/errors v0.8.1 // indirect
 	github.com/stretchr/testify v1.3.0
-	github.com/wernerd/gortp v0.0.0-20180611175553-324aada92a14
 )
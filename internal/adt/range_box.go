@@ -73,8 +73,6 @@ func (r *RangeBox) Request() (uint32, error) {
 
 		return r.current, nil
 	}
-
-	return 0, errors.New("no port is available")
 }
 
 // Release releases a previously requested value from a RangeBox to be
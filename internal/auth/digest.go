@@ -0,0 +1,49 @@
+//
+// Description: RFC 2617 Digest access authentication.
+//
+package auth
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// HA1 computes the hash of the user credentials inside a realm.
+func HA1(username, realm, password string) string {
+	return hash(fmt.Sprintf("%s:%s:%s", username, realm, password))
+}
+
+// HA2 computes the hash of the request method and its URI.
+func HA2(method, uri string) string {
+	return hash(fmt.Sprintf("%s:%s", method, uri))
+}
+
+// Response computes the Digest response expected from the client. If qop
+// is empty, the RFC 2069 compatible response is used and nc and cnonce are
+// ignored.
+func Response(ha1, ha2, nonce, nc, cnonce, qop string) string {
+	if qop == "" {
+		return hash(fmt.Sprintf("%s:%s:%s", ha1, nonce, ha2))
+	}
+
+	return hash(fmt.Sprintf("%s:%s:%s:%s:%s:%s", ha1, nonce, nc, cnonce, qop, ha2))
+}
+
+// RandomString gives a random hexadecimal string, suitable to be used as a
+// cnonce.
+func RandomString() (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func hash(s string) string {
+	h := md5.Sum([]byte(s))
+	return hex.EncodeToString(h[:])
+}
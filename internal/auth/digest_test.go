@@ -0,0 +1,79 @@
+//
+// Description: Digest authentication tests.
+//
+package auth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rsfreitas/go-rtsp/internal/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponse(t *testing.T) {
+	assert := assert.New(t)
+
+	// RFC 2617 section 3.5 example
+	ha1 := auth.HA1("Mufasa", "testrealm@host.com", "Circle Of Life")
+	ha2 := auth.HA2("GET", "/dir/index.html")
+	r := auth.Response(ha1, ha2, "dcd98b7102dd2f0e8b11d0f600bfb0c093",
+		"00000001", "0a4f113b", "auth")
+
+	assert.Equal("6629fae49393a05397450978507c4ef1", r)
+
+	// RFC 2069 section 2.4 example, without qop. The response printed in
+	// the RFC is wrong, this one is computed from its inputs.
+	ha1 = auth.HA1("Mufasa", "testrealm@host.com", "CircleOfLife")
+	r = auth.Response(ha1, ha2, "dcd98b7102dd2f0e8b11d0f600bfb0c093", "", "", "")
+
+	assert.Equal("1949323746fe6a43ef61f9606e7febea", r)
+}
+
+func TestNonceStore(t *testing.T) {
+	assert := assert.New(t)
+
+	n := auth.NewNonceStore(time.Minute)
+	nonce, err := n.New()
+
+	assert.Nil(err)
+	assert.Equal(auth.NonceUnknown, n.Validate("unknown", "00000001"))
+	assert.Equal(auth.NonceValid, n.Validate(nonce, "00000001"))
+	assert.Equal(auth.NonceReplayed, n.Validate(nonce, "00000001"))
+	assert.Equal(auth.NonceValid, n.Validate(nonce, "00000002"))
+	assert.Equal(auth.NonceReplayed, n.Validate(nonce, ""))
+
+	// Nonces are only accepted by the store that created them, unchanged
+	other := auth.NewNonceStore(time.Minute)
+	other.New()
+	assert.Equal(auth.NonceUnknown, other.Validate(nonce, "00000003"))
+	tampered := []byte(nonce)
+	tampered[0] ^= 1
+	assert.Equal(auth.NonceUnknown, n.Validate(string(tampered), "00000003"))
+
+	// Without a nonce count, a nonce can be used only once
+	nonce, _ = n.New()
+	assert.Equal(auth.NonceValid, n.Validate(nonce, ""))
+	assert.Equal(auth.NonceReplayed, n.Validate(nonce, ""))
+	assert.Equal(auth.NonceReplayed, n.Validate(nonce, "00000001"))
+
+	n = auth.NewNonceStore(0)
+	nonce, _ = n.New()
+	time.Sleep(time.Millisecond)
+	assert.Equal(auth.NonceExpired, n.Validate(nonce, ""))
+}
+
+func TestNonceStoreFull(t *testing.T) {
+	assert := assert.New(t)
+	n := auth.NewNonceStore(time.Minute)
+	nonces := make([]string, 5000)
+
+	for i := range nonces {
+		nonces[i], _ = n.New()
+		assert.Equal(auth.NonceValid, n.Validate(nonces[i], ""))
+	}
+
+	// Forgotten nonces can't be used again
+	assert.Equal(auth.NonceExpired, n.Validate(nonces[0], ""))
+	assert.Equal(auth.NonceReplayed, n.Validate(nonces[len(nonces)-1], ""))
+}
@@ -0,0 +1,197 @@
+//
+// Description: Digest nonce control.
+//
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// NonceStatus is the result of a nonce validation.
+type NonceStatus int
+
+const (
+	NonceValid NonceStatus = iota + 1
+	NonceUnknown
+	NonceExpired
+	NonceReplayed
+)
+
+const (
+	// maxUsedNonces is the number of nonces whose use is tracked. When it
+	// is reached, the oldest ones are forgotten and considered expired.
+	maxUsedNonces = 4096
+
+	nonceTimeLength = 8
+	nonceMACLength  = 16
+)
+
+type nonceInfo struct {
+	created time.Time
+	count   uint64
+
+	// single tells if the nonce was used without a nonce count, so it
+	// can't be used again.
+	single bool
+}
+
+// NonceStore gives nonces to clients and keeps track of their use, so they
+// can't be used after their lifetime and requests can't be replayed. Nonces
+// carry their creation time, authenticated by the store secret, so only the
+// ones already used by authenticated requests are kept.
+type NonceStore struct {
+	lifetime time.Duration
+
+	lock   sync.Mutex
+	secret []byte
+	used   map[string]*nonceInfo
+
+	// last is the creation time of the last nonce, so that no two nonces
+	// are the same.
+	last int64
+
+	// forgotten is the creation time of the newest nonce forgotten when
+	// the store was full. Nonces created until then are expired.
+	forgotten time.Time
+}
+
+// New creates a new nonce to be sent to a client.
+func (n *NonceStore) New() (string, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	// The secret is only created with the first nonce
+	if n.secret == nil {
+		secret := make([]byte, 32)
+
+		if _, err := rand.Read(secret); err != nil {
+			return "", err
+		}
+
+		n.secret = secret
+	}
+
+	now := time.Now().UnixNano()
+
+	if now <= n.last {
+		now = n.last + 1
+	}
+
+	n.last = now
+
+	var created [nonceTimeLength]byte
+	binary.BigEndian.PutUint64(created[:], uint64(now))
+
+	return hex.EncodeToString(created[:]) + hex.EncodeToString(n.mac(created[:])), nil
+}
+
+// Validate checks if a nonce, received from a client, can still be used.
+// The nc argument is the nonce count sent by the client, which must always
+// increase for the same nonce. Clients not using it (RFC 2069) can use a
+// nonce only once.
+func (n *NonceStore) Validate(nonce, nc string) NonceStatus {
+	var count uint64
+
+	if nc != "" {
+		var err error
+
+		if count, err = strconv.ParseUint(nc, 16, 32); err != nil || count == 0 {
+			return NonceReplayed
+		}
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	created, ok := n.creation(nonce)
+
+	if !ok {
+		return NonceUnknown
+	}
+
+	if time.Since(created) > n.lifetime || !created.After(n.forgotten) {
+		return NonceExpired
+	}
+
+	info, ok := n.used[nonce]
+
+	if ok && (info.single || nc == "" || count <= info.count) {
+		return NonceReplayed
+	}
+
+	if !ok {
+		n.makeRoom()
+		info = &nonceInfo{created: created}
+		n.used[nonce] = info
+	}
+
+	info.count = count
+	info.single = nc == ""
+
+	return NonceValid
+}
+
+// creation gives the creation time of a nonce given by the store. It must
+// be called with the lock held.
+func (n *NonceStore) creation(nonce string) (time.Time, bool) {
+	b, err := hex.DecodeString(nonce)
+
+	if err != nil || n.secret == nil || len(b) != nonceTimeLength+nonceMACLength {
+		return time.Time{}, false
+	}
+
+	if !hmac.Equal(b[nonceTimeLength:], n.mac(b[:nonceTimeLength])) {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, int64(binary.BigEndian.Uint64(b[:nonceTimeLength]))), true
+}
+
+func (n *NonceStore) mac(created []byte) []byte {
+	h := hmac.New(sha256.New, n.secret)
+	h.Write(created)
+
+	return h.Sum(nil)[:nonceMACLength]
+}
+
+// makeRoom removes expired nonces when the store is full and, if they're
+// not enough, forgets the oldest one. It must be called with the lock held.
+func (n *NonceStore) makeRoom() {
+	if len(n.used) < maxUsedNonces {
+		return
+	}
+
+	var (
+		oldest  string
+		created time.Time
+	)
+
+	for k, v := range n.used {
+		if time.Since(v.created) > n.lifetime {
+			delete(n.used, k)
+		} else if oldest == "" || v.created.Before(created) {
+			oldest, created = k, v.created
+		}
+	}
+
+	if len(n.used) >= maxUsedNonces {
+		delete(n.used, oldest)
+		n.forgotten = created
+	}
+}
+
+// NewNonceStore creates a new NonceStore whose nonces expire after
+// lifetime.
+func NewNonceStore(lifetime time.Duration) *NonceStore {
+	return &NonceStore{
+		lifetime: lifetime,
+		used:     make(map[string]*nonceInfo),
+	}
+}
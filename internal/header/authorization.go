@@ -0,0 +1,234 @@
+//
+// Description: The Authorization and WWW-Authenticate headers (RFC 2617).
+//
+package header
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	AuthorizationSchemeBasic  = "Basic"
+	AuthorizationSchemeDigest = "Digest"
+)
+
+// Authorization holds all RTSP Authorization header parameter already
+// parsed. Depending on its Scheme, only some of its fields are used.
+type Authorization struct {
+	Scheme string
+
+	// Basic and Digest
+	Username string
+
+	// Basic
+	Password string
+
+	// Digest
+	Realm     string
+	Nonce     string
+	URI       string
+	Response  string
+	Algorithm string
+	Opaque    string
+	Qop       string
+	Nc        string
+	Cnonce    string
+}
+
+// String returns the Authorization object in the format required by the
+// RTSP Authorization header parameter.
+func (a *Authorization) String() string {
+	if a.Scheme == AuthorizationSchemeBasic {
+		credentials := a.Username + ":" + a.Password
+		return a.Scheme + " " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+
+	var fields []string
+	fields = appendQuoted(fields, "username", a.Username)
+	fields = appendQuoted(fields, "realm", a.Realm)
+	fields = appendQuoted(fields, "nonce", a.Nonce)
+	fields = appendQuoted(fields, "uri", a.URI)
+	fields = appendQuoted(fields, "response", a.Response)
+	fields = appendQuoted(fields, "opaque", a.Opaque)
+
+	if a.Algorithm != "" {
+		fields = append(fields, "algorithm="+a.Algorithm)
+	}
+
+	if a.Qop != "" {
+		fields = append(fields, "qop="+a.Qop, "nc="+a.Nc)
+		fields = appendQuoted(fields, "cnonce", a.Cnonce)
+	}
+
+	return a.Scheme + " " + strings.Join(fields, ", ")
+}
+
+// NewAuthorizationFromString parses a string, in the RTSP Authorization
+// header format, to an Authorization object.
+func NewAuthorizationFromString(s string) (*Authorization, error) {
+	scheme, value := splitScheme(s)
+
+	switch {
+	case strings.EqualFold(scheme, AuthorizationSchemeBasic):
+		b, err := base64.StdEncoding.DecodeString(value)
+
+		if err != nil {
+			return nil, errors.New("invalid 'Basic' credentials")
+		}
+
+		credentials := strings.SplitN(string(b), ":", 2)
+
+		if len(credentials) != 2 {
+			return nil, errors.New("invalid 'Basic' credentials")
+		}
+
+		return &Authorization{
+			Scheme:   AuthorizationSchemeBasic,
+			Username: credentials[0],
+			Password: credentials[1],
+		}, nil
+
+	case strings.EqualFold(scheme, AuthorizationSchemeDigest):
+		p := parseAuthParameters(value)
+
+		return &Authorization{
+			Scheme:    AuthorizationSchemeDigest,
+			Username:  p["username"],
+			Realm:     p["realm"],
+			Nonce:     p["nonce"],
+			URI:       p["uri"],
+			Response:  p["response"],
+			Algorithm: p["algorithm"],
+			Opaque:    p["opaque"],
+			Qop:       p["qop"],
+			Nc:        p["nc"],
+			Cnonce:    p["cnonce"],
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported authorization scheme '%s'", scheme)
+}
+
+// Authenticate holds all RTSP WWW-Authenticate header parameter, i.e, a
+// challenge sent by the server to the client.
+type Authenticate struct {
+	Scheme    string
+	Realm     string
+	Nonce     string
+	Opaque    string
+	Algorithm string
+	Qop       string
+	Stale     bool
+}
+
+// String returns the Authenticate object in the format required by the
+// RTSP WWW-Authenticate header parameter.
+func (a *Authenticate) String() string {
+	var fields []string
+	fields = appendQuoted(fields, "realm", a.Realm)
+
+	if a.Scheme == AuthorizationSchemeDigest {
+		fields = appendQuoted(fields, "nonce", a.Nonce)
+		fields = appendQuoted(fields, "opaque", a.Opaque)
+		fields = appendQuoted(fields, "qop", a.Qop)
+
+		if a.Algorithm != "" {
+			fields = append(fields, "algorithm="+a.Algorithm)
+		}
+
+		if a.Stale {
+			fields = append(fields, "stale=TRUE")
+		}
+	}
+
+	return a.Scheme + " " + strings.Join(fields, ", ")
+}
+
+// NewAuthenticateFromString parses a string, in the RTSP WWW-Authenticate
+// header format, to an Authenticate object.
+func NewAuthenticateFromString(s string) (*Authenticate, error) {
+	scheme, value := splitScheme(s)
+
+	if !strings.EqualFold(scheme, AuthorizationSchemeBasic) &&
+		!strings.EqualFold(scheme, AuthorizationSchemeDigest) {
+		return nil, fmt.Errorf("unsupported authentication scheme '%s'", scheme)
+	}
+
+	p := parseAuthParameters(value)
+	a := &Authenticate{
+		Scheme:    AuthorizationSchemeBasic,
+		Realm:     p["realm"],
+		Nonce:     p["nonce"],
+		Opaque:    p["opaque"],
+		Algorithm: p["algorithm"],
+		Qop:       p["qop"],
+		Stale:     strings.EqualFold(p["stale"], "true"),
+	}
+
+	if strings.EqualFold(scheme, AuthorizationSchemeDigest) {
+		a.Scheme = AuthorizationSchemeDigest
+	}
+
+	return a, nil
+}
+
+// splitScheme splits an authentication header value into its scheme and
+// its parameters.
+func splitScheme(s string) (string, string) {
+	s = strings.TrimSpace(s)
+
+	if i := strings.IndexAny(s, " \t"); i > 0 {
+		return s[:i], strings.TrimSpace(s[i+1:])
+	}
+
+	return s, ""
+}
+
+// parseAuthParameters parses a comma separated list of key=value
+// parameters, where values may be quoted.
+func parseAuthParameters(s string) map[string]string {
+	p := make(map[string]string)
+
+	for len(s) > 0 {
+		i := strings.Index(s, "=")
+
+		if i < 0 {
+			break
+		}
+
+		key := strings.ToLower(strings.TrimSpace(s[:i]))
+		s = strings.TrimSpace(s[i+1:])
+
+		var value string
+
+		if strings.HasPrefix(s, "\"") {
+			end := strings.Index(s[1:], "\"")
+
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else if end := strings.Index(s, ","); end >= 0 {
+			value, s = strings.TrimSpace(s[:end]), s[end:]
+		} else {
+			value, s = strings.TrimSpace(s), ""
+		}
+
+		p[key] = value
+		s = strings.TrimLeft(s, ", \t")
+	}
+
+	return p
+}
+
+func appendQuoted(fields []string, key, value string) []string {
+	if value == "" {
+		return fields
+	}
+
+	return append(fields, fmt.Sprintf("%s=\"%s\"", key, value))
+}
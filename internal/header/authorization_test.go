@@ -0,0 +1,58 @@
+//
+// Description: Authorization header tests.
+//
+package header_test
+
+import (
+	"testing"
+
+	"github.com/rsfreitas/go-rtsp/internal/header"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAuthorizationBasic(t *testing.T) {
+	assert := assert.New(t)
+
+	a, err := header.NewAuthorizationFromString("Basic QWxhZGRpbjpvcGVuIHNlc2FtZQ==")
+
+	assert.Nil(err)
+	assert.Equal(header.AuthorizationSchemeBasic, a.Scheme)
+	assert.Equal("Aladdin", a.Username)
+	assert.Equal("open sesame", a.Password)
+	assert.Equal("Basic QWxhZGRpbjpvcGVuIHNlc2FtZQ==", a.String())
+}
+
+func TestNewAuthorizationDigest(t *testing.T) {
+	assert := assert.New(t)
+
+	a, err := header.NewAuthorizationFromString(`Digest username="Mufasa", realm="testrealm@host.com", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", uri="/dir/index.html", qop=auth, nc=00000001, cnonce="0a4f113b", response="6629fae49393a05397450978507c4ef1", opaque="5ccc069c403ebaf9f0171e9517f40e41"`)
+
+	assert.Nil(err)
+	assert.Equal(header.AuthorizationSchemeDigest, a.Scheme)
+	assert.Equal("Mufasa", a.Username)
+	assert.Equal("testrealm@host.com", a.Realm)
+	assert.Equal("dcd98b7102dd2f0e8b11d0f600bfb0c093", a.Nonce)
+	assert.Equal("/dir/index.html", a.URI)
+	assert.Equal("auth", a.Qop)
+	assert.Equal("00000001", a.Nc)
+	assert.Equal("0a4f113b", a.Cnonce)
+	assert.Equal("6629fae49393a05397450978507c4ef1", a.Response)
+
+	b, err := header.NewAuthorizationFromString(a.String())
+
+	assert.Nil(err)
+	assert.Equal(a, b)
+}
+
+func TestNewAuthenticate(t *testing.T) {
+	assert := assert.New(t)
+
+	a, err := header.NewAuthenticateFromString(`Digest realm="go-rtsp", nonce="abc", qop="auth", algorithm=MD5, stale=TRUE`)
+
+	assert.Nil(err)
+	assert.Equal(header.AuthorizationSchemeDigest, a.Scheme)
+	assert.Equal("go-rtsp", a.Realm)
+	assert.Equal("abc", a.Nonce)
+	assert.Equal("auth", a.Qop)
+	assert.True(a.Stale)
+}
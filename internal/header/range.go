@@ -27,6 +27,30 @@ type Npt struct {
 	Minutes  int
 	Seconds  int
 	Fraction int
+
+	// fractionDigits is the number of digits of Fraction, so 0.5 and 0.05
+	// may be told apart.
+	fractionDigits int
+}
+
+// Duration gives the offset, from the beginning of the stream, that the npt
+// time refers to. The "now" time doesn't have an offset and gives 0.
+func (n *Npt) Duration() time.Duration {
+	if n.Type == NptNow {
+		return 0
+	}
+
+	d := time.Duration(n.Hours)*time.Hour +
+		time.Duration(n.Minutes)*time.Minute +
+		time.Duration(n.Seconds)*time.Second
+
+	fraction := time.Duration(n.Fraction) * time.Second
+
+	for i := 0; i < n.fractionDigits; i++ {
+		fraction /= 10
+	}
+
+	return d + fraction
 }
 
 type SmpteType int
@@ -45,6 +69,27 @@ type SmpteTime struct {
 	Subframes int
 }
 
+// Duration gives the offset, from the beginning of the stream, that the
+// smpte time refers to, using the frame rate of its type.
+func (s SmpteTime) Duration(t SmpteType) time.Duration {
+	fps := 30.0
+
+	switch t {
+	case Smpte30Drop:
+		fps = 29.97
+
+	case Smpte25:
+		fps = 25
+	}
+
+	frames := float64(s.Frames) + float64(s.Subframes)/100
+
+	return time.Duration(s.Hours)*time.Hour +
+		time.Duration(s.Minutes)*time.Minute +
+		time.Duration(s.Seconds)*time.Second +
+		time.Duration(frames/fps*float64(time.Second))
+}
+
 type Smpte struct {
 	Type SmpteType
 	Time []SmpteTime
@@ -140,10 +185,11 @@ func newSmpteTime(s string) (SmpteTime, error) {
 	return st, nil
 }
 
-func parseSeconds(s string) (int, int, error) {
+func parseSeconds(s string) (int, int, int, error) {
 	var (
 		seconds  int
 		fraction int
+		digits   int
 		err      error
 	)
 
@@ -152,23 +198,25 @@ func parseSeconds(s string) (int, int, error) {
 		seconds, err = strconv.Atoi(t[0])
 
 		if err != nil {
-			return 0, 0, errors.New("invalid 'npt' seconds field")
+			return 0, 0, 0, errors.New("invalid 'npt' seconds field")
 		}
 
 		fraction, err = strconv.Atoi(t[1])
 
 		if err != nil {
-			return 0, 0, errors.New("invalid 'npt' seconds fraction field")
+			return 0, 0, 0, errors.New("invalid 'npt' seconds fraction field")
 		}
+
+		digits = len(t[1])
 	} else {
 		seconds, err = strconv.Atoi(s)
 
 		if err != nil {
-			return 0, 0, errors.New("invalid 'npt' seconds field")
+			return 0, 0, 0, errors.New("invalid 'npt' seconds field")
 		}
 	}
 
-	return seconds, fraction, nil
+	return seconds, fraction, digits, nil
 }
 
 func newNpt(s string) (*Npt, error) {
@@ -196,14 +244,14 @@ func newNpt(s string) (*Npt, error) {
 			return nil, errors.New("invalid 'npt' minutes field")
 		}
 
-		n.Seconds, n.Fraction, err = parseSeconds(t[2])
+		n.Seconds, n.Fraction, n.fractionDigits, err = parseSeconds(t[2])
 
 		if err != nil {
 			return nil, err
 		}
 	} else {
 		n.Type = NptSec
-		n.Seconds, n.Fraction, err = parseSeconds(s)
+		n.Seconds, n.Fraction, n.fractionDigits, err = parseSeconds(s)
 
 		if err != nil {
 			return nil, err
@@ -213,6 +261,37 @@ func newNpt(s string) (*Npt, error) {
 	return n, nil
 }
 
+// NewNptRange creates a npt range between two offsets of a stream. An end
+// of zero leaves the range open.
+func NewNptRange(start, end time.Duration) *Range {
+	r := &Range{
+		parameters: map[string][]string{
+			"npt": {formatNpt(start), ""},
+		},
+		Npt: []*Npt{newNptFromDuration(start)},
+	}
+
+	if end > 0 {
+		r.parameters["npt"][1] = formatNpt(end)
+		r.Npt = append(r.Npt, newNptFromDuration(end))
+	}
+
+	return r
+}
+
+func formatNpt(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+func newNptFromDuration(d time.Duration) *Npt {
+	return &Npt{
+		Type:           NptSec,
+		Seconds:        int(d / time.Second),
+		Fraction:       int(d % time.Second / time.Millisecond),
+		fractionDigits: 3,
+	}
+}
+
 func NewRange(in string) (*Range, error) {
 	r := &Range{
 		parameters: make(map[string][]string),
@@ -280,7 +359,12 @@ func NewRange(in string) (*Range, error) {
 	}
 
 	if ok {
-		for _, s := range f {
+		for i, s := range f {
+			// An open range doesn't have its end time
+			if s == "" && i > 0 {
+				continue
+			}
+
 			st, err := newSmpteTime(s)
 
 			if err != nil {
@@ -296,7 +380,17 @@ func NewRange(in string) (*Range, error) {
 	if f, ok := r.parameters["npt"]; ok {
 		var npt []*Npt
 
-		for _, s := range f {
+		for i, s := range f {
+			// An open range doesn't have its end time, while a missing
+			// start means the beginning of the stream.
+			if s == "" {
+				if i > 0 {
+					continue
+				}
+
+				s = "0"
+			}
+
 			n, err := newNpt(s)
 
 			if err != nil {
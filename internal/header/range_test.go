@@ -8,6 +8,7 @@ package header_test
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/rsfreitas/go-rtsp/internal/header"
 	"github.com/stretchr/testify/assert"
@@ -25,3 +26,38 @@ func TestNewRange(t *testing.T) {
 	fmt.Println(r)
 	assert.Equal(1, 1, "Should be equal")
 }
+
+func TestNptRange(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := header.NewRange("npt=30-")
+	assert.Nil(err)
+	assert.Len(r.Npt, 1)
+	assert.Equal(30*time.Second, r.Npt[0].Duration())
+
+	r, err = header.NewRange("npt=0:01:02.05-0:02:00.5")
+	assert.Nil(err)
+	assert.Len(r.Npt, 2)
+	assert.Equal(62*time.Second+50*time.Millisecond, r.Npt[0].Duration())
+	assert.Equal(120*time.Second+500*time.Millisecond, r.Npt[1].Duration())
+
+	r, err = header.NewRange("npt=now-")
+	assert.Nil(err)
+	assert.Equal(header.NptNow, r.Npt[0].Type)
+
+	r, err = header.NewRange("smpte-25=0:00:10:05-")
+	assert.Nil(err)
+	assert.Len(r.Smpte.Time, 1)
+	assert.Equal(10*time.Second+200*time.Millisecond, r.Smpte.Time[0].Duration(r.Smpte.Type))
+}
+
+func TestNewNptRange(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("npt=30.000-", header.NewNptRange(30*time.Second, 0).String())
+	assert.Equal("npt=1.500-90.250", header.NewNptRange(1500*time.Millisecond, 90250*time.Millisecond).String())
+
+	r, err := header.NewRange(header.NewNptRange(1500*time.Millisecond, 0).String())
+	assert.Nil(err)
+	assert.Equal(1500*time.Millisecond, r.Npt[0].Duration())
+}
@@ -0,0 +1,133 @@
+//
+// Description: The RTP-Info header (RFC 2326 section 12.33).
+//
+package header
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RTPInfoTrack holds the RTP-Info of a single stream track: the sequence
+// number and timestamp of the first packet sent after a PLAY request.
+type RTPInfoTrack struct {
+	URL string
+
+	// Sequence and RTPTime are optional, so HasSequence and HasRTPTime
+	// tell if they were informed.
+	Sequence    uint16
+	RTPTime     uint32
+	HasSequence bool
+	HasRTPTime  bool
+}
+
+func (t *RTPInfoTrack) String() string {
+	s := "url=" + t.URL
+
+	if t.HasSequence {
+		s += fmt.Sprintf(";seq=%d", t.Sequence)
+	}
+
+	if t.HasRTPTime {
+		s += fmt.Sprintf(";rtptime=%d", t.RTPTime)
+	}
+
+	return s
+}
+
+// RTPInfo holds the RTSP RTP-Info header, with an entry for every track
+// being played.
+type RTPInfo struct {
+	Tracks []RTPInfoTrack
+}
+
+// Append adds the information of a track.
+func (r *RTPInfo) Append(url string, sequence uint16, rtpTime uint32) {
+	r.Tracks = append(r.Tracks, RTPInfoTrack{
+		URL:         url,
+		Sequence:    sequence,
+		RTPTime:     rtpTime,
+		HasSequence: true,
+		HasRTPTime:  true,
+	})
+}
+
+// String returns the RTPInfo object in the format required by the RTSP
+// RTP-Info header.
+func (r *RTPInfo) String() string {
+	var tracks []string
+
+	for _, t := range r.Tracks {
+		tracks = append(tracks, t.String())
+	}
+
+	return strings.Join(tracks, ",")
+}
+
+func newRTPInfoTrack(s string) (RTPInfoTrack, error) {
+	var t RTPInfoTrack
+
+	for _, p := range strings.Split(s, ";") {
+		f := strings.SplitN(strings.TrimSpace(p), "=", 2)
+
+		if len(f) != 2 {
+			return RTPInfoTrack{}, errors.New("invalid 'RTP-Info' parameter")
+		}
+
+		switch f[0] {
+		case "url":
+			t.URL = f[1]
+
+		case "seq":
+			n, err := strconv.ParseUint(f[1], 10, 16)
+
+			if err != nil {
+				return RTPInfoTrack{}, errors.New("invalid 'RTP-Info' seq field")
+			}
+
+			t.Sequence = uint16(n)
+			t.HasSequence = true
+
+		case "rtptime":
+			n, err := strconv.ParseUint(f[1], 10, 32)
+
+			if err != nil {
+				return RTPInfoTrack{}, errors.New("invalid 'RTP-Info' rtptime field")
+			}
+
+			t.RTPTime = uint32(n)
+			t.HasRTPTime = true
+		}
+	}
+
+	if t.URL == "" {
+		return RTPInfoTrack{}, errors.New("'RTP-Info' without url field")
+	}
+
+	return t, nil
+}
+
+// NewRTPInfo creates a new empty RTPInfo object.
+func NewRTPInfo() *RTPInfo {
+	return &RTPInfo{}
+}
+
+// NewRTPInfoFromString parses a string, in the RTSP RTP-Info header format,
+// to a RTPInfo object.
+func NewRTPInfoFromString(s string) (*RTPInfo, error) {
+	r := NewRTPInfo()
+
+	for _, entry := range strings.Split(s, ",") {
+		t, err := newRTPInfoTrack(entry)
+
+		if err != nil {
+			return nil, err
+		}
+
+		r.Tracks = append(r.Tracks, t)
+	}
+
+	return r, nil
+}
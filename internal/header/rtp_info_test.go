@@ -0,0 +1,49 @@
+//
+// Description: RTP-Info header tests.
+//
+package header_test
+
+import (
+	"testing"
+
+	"github.com/rsfreitas/go-rtsp/internal/header"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRTPInfoFromString(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := header.NewRTPInfoFromString("url=rtsp://foo.com/bar.avi/streamid=0;seq=45102," +
+		"url=rtsp://foo.com/bar.avi/streamid=1;seq=30211;rtptime=2890844526")
+
+	assert.Nil(err)
+	assert.Len(r.Tracks, 2)
+	assert.Equal("rtsp://foo.com/bar.avi/streamid=0", r.Tracks[0].URL)
+	assert.Equal(uint16(45102), r.Tracks[0].Sequence)
+	assert.True(r.Tracks[0].HasSequence)
+	assert.False(r.Tracks[0].HasRTPTime)
+	assert.Equal(uint16(30211), r.Tracks[1].Sequence)
+	assert.Equal(uint32(2890844526), r.Tracks[1].RTPTime)
+
+	_, err = header.NewRTPInfoFromString("seq=45102")
+	assert.NotNil(err)
+
+	_, err = header.NewRTPInfoFromString("url=rtsp://foo.com/bar.avi;seq=70000")
+	assert.NotNil(err)
+}
+
+func TestRTPInfoString(t *testing.T) {
+	assert := assert.New(t)
+
+	r := header.NewRTPInfo()
+	r.Append("rtsp://host/cam1/trackID=0", 1200, 90000)
+	r.Append("rtsp://host/cam1/trackID=1", 5, 44100)
+
+	s := r.String()
+	assert.Equal("url=rtsp://host/cam1/trackID=0;seq=1200;rtptime=90000,"+
+		"url=rtsp://host/cam1/trackID=1;seq=5;rtptime=44100", s)
+
+	parsed, err := header.NewRTPInfoFromString(s)
+	assert.Nil(err)
+	assert.Equal(r, parsed)
+}
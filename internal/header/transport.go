@@ -79,6 +79,17 @@ func (t *Transport) SetProfile() {
 	t.Profile = "AVP"
 }
 
+// SetSecureProfile sets the SRTP profile (RTP/SAVP), whose packets are
+// encrypted and authenticated.
+func (t *Transport) SetSecureProfile() {
+	t.Profile = "SAVP"
+}
+
+// IsSecure tells if the transport uses the SRTP profile (RTP/SAVP).
+func (t *Transport) IsSecure() bool {
+	return strings.EqualFold(t.Profile, "SAVP")
+}
+
 func (t *Transport) SetLowerTransport(lowerTransport TransportLowerType) {
 	switch lowerTransport {
 	case TransportLowerUDP:
@@ -117,6 +128,12 @@ func (t *Transport) String() string {
 	return s
 }
 
+// IsRecord tells if the transport is used to send data to the server
+// (mode=record), instead of receiving it.
+func (t *Transport) IsRecord() bool {
+	return strings.EqualFold(strings.Trim(t.Mode, "\""), "record")
+}
+
 func (t *Transport) HasParameter(parameter string) bool {
 	_, ok := t.parameters[parameter]
 
@@ -25,3 +25,22 @@ func TestNewTransport(t *testing.T) {
 	fmt.Println(r)
 	assert.Equal(1, 1, "Should be equal")
 }
+
+func TestTransportSecureProfile(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := header.NewTransportFromString("RTP/SAVP;unicast;client_port=8000-8001")
+	assert.Nil(err)
+	assert.True(r.IsSecure())
+	assert.Equal("UDP", r.LowerTransport)
+
+	r, err = header.NewTransportFromString("RTP/AVP/TCP;unicast;interleaved=0-1")
+	assert.Nil(err)
+	assert.False(r.IsSecure())
+
+	r = header.NewTransport()
+	r.SetTransport(header.TransportRTP)
+	r.SetSecureProfile()
+	r.SetLowerTransport(header.TransportLowerTCP)
+	assert.Equal("RTP/SAVP/TCP", r.String())
+}
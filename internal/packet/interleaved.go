@@ -0,0 +1,176 @@
+//
+// Description: RTP/RTCP interleaved frames (RFC 2326 section 10.12).
+//
+package packet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+const (
+	// InterleavedMagic is the first byte of every interleaved frame sent
+	// over the RTSP connection.
+	InterleavedMagic = '$'
+
+	interleavedHeaderSize = 4
+	maxRequestSize        = 65536
+)
+
+var errRequestTooLarge = errors.New("request too large")
+
+// IsInterleavedFrame checks if the next available data inside the reader
+// is an interleaved frame instead of a RTSP request.
+func IsInterleavedFrame(r *bufio.Reader) (bool, error) {
+	b, err := r.Peek(1)
+
+	if err != nil {
+		return false, err
+	}
+
+	return b[0] == InterleavedMagic, nil
+}
+
+// ReadInterleavedFrame reads an interleaved frame from the reader, returning
+// its channel and its payload.
+func ReadInterleavedFrame(r *bufio.Reader) (int, []byte, error) {
+	var header [interleavedHeaderSize]byte
+
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+
+	if header[0] != InterleavedMagic {
+		return 0, nil, errors.New("invalid interleaved frame")
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[2:]))
+
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+
+	return int(header[1]), payload, nil
+}
+
+// MarshalInterleavedFrame puts payload inside an interleaved frame to be
+// sent over the RTSP connection using a specific channel.
+func MarshalInterleavedFrame(channel int, payload []byte) ([]byte, error) {
+	if channel < 0 || channel > 255 {
+		return nil, errors.New("invalid interleaved channel")
+	}
+
+	if len(payload) > 65535 {
+		return nil, errors.New("interleaved payload too large")
+	}
+
+	b := make([]byte, interleavedHeaderSize+len(payload))
+	b[0] = InterleavedMagic
+	b[1] = byte(channel)
+	binary.BigEndian.PutUint16(b[2:], uint16(len(payload)))
+	copy(b[interleavedHeaderSize:], payload)
+
+	return b, nil
+}
+
+// ReadRequest reads a complete RTSP request, i.e, its request line, headers
+// and body (if a Content-Length is found), from the reader. The returned
+// data is suitable to be used with UnmarshalRequest.
+func ReadRequest(r *bufio.Reader) ([]byte, error) {
+	return readMessage(r)
+}
+
+// ReadResponse reads a complete RTSP response, i.e, its status line, headers
+// and body (if a Content-Length is found), from the reader. The returned
+// data is suitable to be used with UnmarshalResponse.
+func ReadResponse(r *bufio.Reader) ([]byte, error) {
+	return readMessage(r)
+}
+
+// readLine reads a line from the reader, without its line ending. It fails
+// as soon as the line is longer than limit, so a line without end can't
+// take any memory it wants.
+func readLine(r *bufio.Reader, limit int) ([]byte, error) {
+	var line []byte
+
+	for {
+		b, err := r.ReadSlice('\n')
+
+		if len(line)+len(b) > limit {
+			return nil, errRequestTooLarge
+		}
+
+		line = append(line, b...)
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		line = bytes.TrimSuffix(line[:len(line)-1], []byte{'\r'})
+
+		return line, nil
+	}
+}
+
+// readMessage reads a complete RTSP message, request or response, from the
+// reader.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	var (
+		request       []byte
+		contentLength int
+	)
+
+	for {
+		line, err := readLine(r, maxRequestSize-len(request))
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Skips blank lines between requests
+		if len(line) == 0 && len(request) == 0 {
+			continue
+		}
+
+		request = append(request, line...)
+		request = append(request, '\r', '\n')
+
+		if len(line) == 0 {
+			break
+		}
+
+		if i := strings.Index(string(line), ":"); i > 0 {
+			key := textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(string(line[:i])))
+
+			if key == "Content-Length" {
+				contentLength, err = strconv.Atoi(strings.TrimSpace(string(line[i+1:])))
+
+				if err != nil || contentLength < 0 || contentLength > maxRequestSize {
+					return nil, errors.New("invalid Content-Length")
+				}
+			}
+		}
+	}
+
+	if contentLength > 0 {
+		body := make([]byte, contentLength)
+
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, err
+		}
+
+		request = append(request, body...)
+	}
+
+	return request, nil
+}
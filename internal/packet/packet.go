@@ -10,6 +10,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/textproto"
 	"net/url"
 	"strconv"
@@ -18,6 +19,8 @@ import (
 	"github.com/gortc/sdp"
 )
 
+const defaultVersion = "RTSP/1.0"
+
 type Packet struct {
 	*Request
 	*Response
@@ -97,6 +100,8 @@ func (p *Packet) parseHeadersAndBody(in []byte, start, length int) error {
 			return errors.New("read less bytes than Content-Length")
 		}
 
+		p.Request.Body = body
+
 		if err := p.parseBody(body); err != nil {
 			return err
 		}
@@ -105,16 +110,6 @@ func (p *Packet) parseHeadersAndBody(in []byte, start, length int) error {
 	return nil
 }
 
-func (p *Packet) StillNeedsRead(in []byte, length int) bool {
-	s := in[length-4 : length]
-
-	if s[0] == '\r' && s[1] == '\n' && s[2] == '\r' && s[3] == '\n' {
-		return false
-	}
-
-	return true
-}
-
 func (p *Packet) UnmarshalRequest(in []byte, length int) error {
 	var (
 		err    error
@@ -122,8 +117,6 @@ func (p *Packet) UnmarshalRequest(in []byte, length int) error {
 		offset int
 	)
 
-	fmt.Printf("--BEGIN--\n|%s|\n--END--\n", string(in))
-
 method:
 	for i := 0; i < length; i++ {
 		switch in[i] {
@@ -215,6 +208,10 @@ func (p *Packet) MarshalResponse() ([]byte, error) {
 	b.WriteString(fmt.Sprintf("Cseq: %d\r\n", p.Request.sequence))
 
 	// Response.Headers
+	if len(p.Response.Body) > 0 && p.Response.Headers.Get("Content-Length") == "" {
+		p.Response.Headers.Set("Content-Length", strconv.Itoa(len(p.Response.Body)))
+	}
+
 	for k, v := range p.Response.Headers {
 		b.WriteString(fmt.Sprintf("%s: %s\r\n", k, v[0]))
 	}
@@ -223,14 +220,93 @@ func (p *Packet) MarshalResponse() ([]byte, error) {
 
 	// Body
 	if p.Response.Body != nil {
-		b.WriteString(fmt.Sprintf("%s\r\n", string(p.Response.Body)))
+		b.Write(p.Response.Body)
+	}
+
+	return b.Bytes(), nil
+}
+
+// MarshalRequest gives the request in the RTSP format, to be sent to a
+// server. Its Cseq must be set inside the request headers.
+func (p *Packet) MarshalRequest() ([]byte, error) {
+	if p.Request.URL == nil {
+		return nil, errors.New("missing URL")
+	}
+
+	version := p.Request.Version
+
+	if version == "" {
+		version = defaultVersion
+	}
+
+	var b bytes.Buffer
+
+	b.WriteString(fmt.Sprintf("%s %s %s\r\n", p.Request.Method,
+		p.Request.URL.String(), version))
+
+	for k, values := range p.Request.Headers {
+		for _, v := range values {
+			b.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
+		}
+	}
+
+	if _, ok := p.Request.Headers["Content-Length"]; !ok && len(p.Request.Body) > 0 {
+		b.WriteString(fmt.Sprintf("Content-Length: %d\r\n", len(p.Request.Body)))
 	}
 
 	b.WriteString("\r\n")
+	b.Write(p.Request.Body)
 
 	return b.Bytes(), nil
 }
 
+// UnmarshalResponse parses a RTSP response, as read by ReadResponse, such
+// as the ones received by a client.
+func (p *Packet) UnmarshalResponse(in []byte) error {
+	reader := bufio.NewReader(bytes.NewReader(in))
+	tp := textproto.NewReader(reader)
+	line, err := tp.ReadLine()
+
+	if err != nil {
+		return err
+	}
+
+	f := strings.SplitN(line, " ", 3)
+
+	if len(f) < 2 || !strings.HasPrefix(f[0], "RTSP/") {
+		return errors.New("invalid status line")
+	}
+
+	p.Response.StatusCode, err = strconv.Atoi(f[1])
+
+	if err != nil {
+		return errors.New("invalid status code")
+	}
+
+	if len(f) > 2 {
+		p.Response.StatusText = f[2]
+	}
+
+	p.Response.Headers, err = tp.ReadMIMEHeader()
+
+	if err != nil {
+		return err
+	}
+
+	// ReadResponse already limits the body to its Content-Length
+	body, err := ioutil.ReadAll(reader)
+
+	if err != nil {
+		return err
+	}
+
+	if len(body) > 0 {
+		p.Response.Body = body
+	}
+
+	return nil
+}
+
 func (p *Packet) MarshalResponseError(err error) []byte {
 	// TODO
 	return nil
@@ -0,0 +1,103 @@
+//
+// Description: RTSP message tests.
+//
+package packet_test
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rsfreitas/go-rtsp/internal/packet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalRequest(t *testing.T) {
+	assert := assert.New(t)
+	u, _ := url.Parse("rtsp://host/cam1")
+
+	p := packet.NewPacket()
+	p.Request.Method = "DESCRIBE"
+	p.Request.URL = u
+	p.Request.Headers = map[string][]string{
+		"CSeq": {"2"},
+	}
+
+	b, err := p.MarshalRequest()
+	assert.Nil(err)
+	assert.Equal("DESCRIBE rtsp://host/cam1 RTSP/1.0\r\nCSeq: 2\r\n\r\n", string(b))
+
+	// The server side parses it back
+	r, err := packet.ReadRequest(bufio.NewReader(bytes.NewReader(b)))
+	assert.Nil(err)
+
+	parsed := packet.NewPacket()
+	assert.Nil(parsed.UnmarshalRequest(r, len(r)))
+	assert.Equal("DESCRIBE", parsed.Request.Method)
+	assert.Equal(uint64(2), parsed.Request.Sequence())
+}
+
+func TestUnmarshalResponse(t *testing.T) {
+	assert := assert.New(t)
+	in := "RTSP/1.0 200 OK\r\nCseq: 3\r\nContent-Length: 5\r\n\r\nv=0\r\n" +
+		"RTSP/1.0 401 Unauthorized\r\nCseq: 4\r\n\r\n"
+
+	reader := bufio.NewReader(bytes.NewReader([]byte(in)))
+
+	b, err := packet.ReadResponse(reader)
+	assert.Nil(err)
+
+	p := packet.NewPacket()
+	assert.Nil(p.UnmarshalResponse(b))
+	assert.Equal(200, p.Response.StatusCode)
+	assert.Equal("OK", p.Response.StatusText)
+	assert.Equal("3", p.Response.Headers.Get("CSeq"))
+	assert.Equal([]byte("v=0\r\n"), p.Response.Body)
+
+	b, err = packet.ReadResponse(reader)
+	assert.Nil(err)
+
+	p = packet.NewPacket()
+	assert.Nil(p.UnmarshalResponse(b))
+	assert.Equal(401, p.Response.StatusCode)
+	assert.Nil(p.Response.Body)
+
+	assert.NotNil(packet.NewPacket().UnmarshalResponse([]byte("DESCRIBE rtsp://host RTSP/1.0\r\n\r\n")))
+}
+
+// endlessReader gives an endless line, counting the bytes read.
+type endlessReader struct {
+	n int
+}
+
+func (r *endlessReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 'a'
+	}
+
+	r.n += len(b)
+
+	return len(b), nil
+}
+
+func TestReadRequestTooLarge(t *testing.T) {
+	assert := assert.New(t)
+
+	// The line is refused before being read entirely
+	r := &endlessReader{}
+	_, err := packet.ReadRequest(bufio.NewReader(r))
+	assert.NotNil(err)
+	assert.True(r.n < 2*65536)
+
+	// As well as too many header lines
+	in := "OPTIONS * RTSP/1.0\r\n" + strings.Repeat("X-Header: value\r\n", 5000) + "\r\n"
+	_, err = packet.ReadRequest(bufio.NewReader(strings.NewReader(in)))
+	assert.NotNil(err)
+
+	in = "OPTIONS * RTSP/1.0\n" + strings.Repeat("X-Header: value\n", 100) + "\n"
+	b, err := packet.ReadRequest(bufio.NewReader(strings.NewReader(in)))
+	assert.Nil(err)
+	assert.True(strings.HasSuffix(string(b), "X-Header: value\r\n\r\n"))
+}
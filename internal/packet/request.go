@@ -18,6 +18,7 @@ type Request struct {
 	Method  string
 	Headers map[string][]string
 	SDP     sdp.Session
+	Body    []byte
 
 	sequence uint64
 }
@@ -31,3 +32,8 @@ func (r *Request) loadInfoFromHeaders() {
 		}
 	}
 }
+
+// Sequence gives the request Cseq.
+func (r *Request) Sequence() uint64 {
+	return r.sequence
+}
@@ -0,0 +1,509 @@
+//
+// Description: AAC RTP payload formats (RFC 3640 and RFC 3016).
+//
+package rtp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+const (
+	// AACObjectTypeLC is the AAC Low Complexity audio object type, the
+	// most common one.
+	AACObjectTypeLC = 2
+
+	aacAUHeaderSize = 2
+	adtsHeaderSize  = 7
+)
+
+var aacSampleRates = []int{
+	96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000,
+	11025, 8000, 7350,
+}
+
+// bitWriter writes values, bit by bit, into a buffer.
+type bitWriter struct {
+	b []byte
+	n uint
+}
+
+func (w *bitWriter) write(value uint32, bits uint) {
+	for i := int(bits) - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.b = append(w.b, 0)
+		}
+
+		if value&(1<<uint(i)) != 0 {
+			w.b[len(w.b)-1] |= 0x80 >> (w.n % 8)
+		}
+
+		w.n++
+	}
+}
+
+// bitReader reads values, bit by bit, from a buffer.
+type bitReader struct {
+	b        []byte
+	n        uint
+	overflow bool
+}
+
+func (r *bitReader) read(bits uint) uint32 {
+	var value uint32
+
+	for i := uint(0); i < bits; i++ {
+		if int(r.n/8) >= len(r.b) {
+			r.overflow = true
+			return 0
+		}
+
+		value <<= 1
+
+		if r.b[r.n/8]&(0x80>>(r.n%8)) != 0 {
+			value |= 1
+		}
+
+		r.n++
+	}
+
+	return value
+}
+
+// writeAudioSpecificConfig writes an AudioSpecificConfig (ISO 14496-3
+// section 1.6.2.1) with a GASpecificConfig.
+func (w *bitWriter) writeAudioSpecificConfig(objectType, sampleRate, channels int) {
+	w.write(uint32(objectType), 5)
+	index := -1
+
+	for i, rate := range aacSampleRates {
+		if rate == sampleRate {
+			index = i
+			break
+		}
+	}
+
+	if index < 0 {
+		w.write(0x0f, 4)
+		w.write(uint32(sampleRate), 24)
+	} else {
+		w.write(uint32(index), 4)
+	}
+
+	w.write(uint32(channels), 4)
+
+	// frameLengthFlag, dependsOnCoreCoder and extensionFlag
+	w.write(0, 3)
+}
+
+// AudioSpecificConfig builds the AAC decoder configuration of a stream.
+func AudioSpecificConfig(objectType, sampleRate, channels int) []byte {
+	var w bitWriter
+	w.writeAudioSpecificConfig(objectType, sampleRate, channels)
+
+	return w.b
+}
+
+// streamMuxConfig builds the LATM decoder configuration of a stream (ISO
+// 14496-3 section 1.7.3), with a single program and layer.
+func streamMuxConfig(objectType, sampleRate, channels int) []byte {
+	var w bitWriter
+
+	w.write(0, 1) // audioMuxVersion
+	w.write(1, 1) // allStreamsSameTimeFraming
+	w.write(0, 6) // numSubFrames
+	w.write(0, 4) // numProgram
+	w.write(0, 3) // numLayer
+	w.writeAudioSpecificConfig(objectType, sampleRate, channels)
+	w.write(0, 3)    // frameLengthType
+	w.write(0xff, 8) // latmBufferFullness
+	w.write(0, 1)    // otherDataPresent
+	w.write(0, 1)    // crcCheckPresent
+
+	return w.b
+}
+
+// splitADTS removes the ADTS headers from a sample, giving its raw access
+// units. Samples without ADTS headers are considered a single access unit.
+func splitADTS(sample []byte) [][]byte {
+	if len(sample) < adtsHeaderSize || sample[0] != 0xff || sample[1]&0xf0 != 0xf0 {
+		return [][]byte{sample}
+	}
+
+	var aus [][]byte
+
+	for len(sample) >= adtsHeaderSize && sample[0] == 0xff && sample[1]&0xf0 == 0xf0 {
+		headerSize := adtsHeaderSize
+
+		// protection_absent unset, so we have a CRC
+		if sample[1]&0x01 == 0 {
+			headerSize += 2
+		}
+
+		frameSize := int(sample[3]&0x03)<<11 | int(sample[4])<<3 | int(sample[5]>>5)
+
+		if frameSize < headerSize || frameSize > len(sample) {
+			break
+		}
+
+		aus = append(aus, sample[headerSize:frameSize])
+		sample = sample[frameSize:]
+	}
+
+	return aus
+}
+
+// AACPacketizer splits AAC samples into RTP payloads using the
+// mpeg4-generic AAC-hbr mode. Several access units are put in the same
+// payload when possible, and larger ones are fragmented.
+type AACPacketizer struct {
+	maxPayloadSize int
+}
+
+// Packetize splits a sample, with one or more raw or ADTS access units,
+// into RTP payloads.
+func (a *AACPacketizer) Packetize(sample []byte) [][]byte {
+	var (
+		payloads [][]byte
+		group    [][]byte
+		size     = aacAUHeaderSize
+	)
+
+	flush := func() {
+		if len(group) > 0 {
+			payloads = append(payloads, aacPayload(group, nil))
+		}
+
+		group = nil
+		size = aacAUHeaderSize
+	}
+
+	for _, au := range splitADTS(sample) {
+		if aacAUHeaderSize*2+len(au) > a.maxPayloadSize {
+			flush()
+			payloads = append(payloads, aacFragment(au, a.maxPayloadSize)...)
+			continue
+		}
+
+		if size+aacAUHeaderSize+len(au) > a.maxPayloadSize {
+			flush()
+		}
+
+		group = append(group, au)
+		size += aacAUHeaderSize + len(au)
+	}
+
+	flush()
+
+	return payloads
+}
+
+// aacPayload builds a payload with the AU-headers section followed by the
+// access units. When fragment is used, it carries a piece of a single
+// access unit, whose size is aus[0].
+func aacPayload(aus [][]byte, fragment []byte) []byte {
+	headersSize := aacAUHeaderSize * len(aus)
+	b := make([]byte, 2+headersSize)
+
+	// AU-headers-length is in bits
+	binary.BigEndian.PutUint16(b, uint16(headersSize*8))
+
+	for i, au := range aus {
+		// 13 bits of AU-size and 3 bits of AU-Index(-delta), always 0
+		binary.BigEndian.PutUint16(b[2+aacAUHeaderSize*i:], uint16(len(au)<<3))
+	}
+
+	if fragment != nil {
+		return append(b, fragment...)
+	}
+
+	for _, au := range aus {
+		b = append(b, au...)
+	}
+
+	return b
+}
+
+// aacFragment splits an access unit into several payloads, all of them
+// with its complete size inside the AU-header.
+func aacFragment(au []byte, maxPayloadSize int) [][]byte {
+	var payloads [][]byte
+	chunk := maxPayloadSize - 2 - aacAUHeaderSize
+
+	for data := au; len(data) > 0; {
+		n := chunk
+
+		if n > len(data) {
+			n = len(data)
+		}
+
+		payloads = append(payloads, aacPayload([][]byte{au}, data[:n]))
+		data = data[n:]
+	}
+
+	return payloads
+}
+
+// AACDepacketizer reassembles AAC access units from mpeg4-generic AAC-hbr
+// RTP payloads.
+type AACDepacketizer struct {
+	fragment []byte
+	size     int
+	sequence uint16
+	started  bool
+}
+
+// Depacketize receives a RTP packet and gives the access units found
+// inside it, as a sequence of raw AAC frames.
+func (a *AACDepacketizer) Depacketize(p *Packet) ([]byte, bool) {
+	var sample []byte
+
+	for _, au := range a.DepacketizeFrames(p) {
+		sample = append(sample, au...)
+	}
+
+	return sample, len(sample) > 0
+}
+
+// DepacketizeFrames receives a RTP packet and gives the access units found
+// inside it, each one as a raw AAC frame.
+func (a *AACDepacketizer) DepacketizeFrames(p *Packet) [][]byte {
+	if a.started && p.Sequence != a.sequence+1 {
+		a.fragment = nil
+	}
+
+	a.started = true
+	a.sequence = p.Sequence
+
+	if len(p.Payload) < 2 {
+		return nil
+	}
+
+	headersSize := int(binary.BigEndian.Uint16(p.Payload)) / 8
+	count := headersSize / aacAUHeaderSize
+	data := p.Payload[2:]
+
+	if headersSize > len(data) || count == 0 {
+		return nil
+	}
+
+	headers := data[:headersSize]
+	data = data[headersSize:]
+
+	// A fragment of a larger access unit
+	if count == 1 {
+		size := int(binary.BigEndian.Uint16(headers) >> 3)
+
+		if size > len(data) {
+			if a.fragment == nil || a.size != size {
+				a.fragment = make([]byte, 0, size)
+				a.size = size
+			}
+
+			a.fragment = append(a.fragment, data...)
+
+			if len(a.fragment) < size {
+				return nil
+			}
+
+			au := a.fragment
+			a.fragment = nil
+
+			return [][]byte{au}
+		}
+	}
+
+	var aus [][]byte
+
+	for i := 0; i < count; i++ {
+		size := int(binary.BigEndian.Uint16(headers[aacAUHeaderSize*i:]) >> 3)
+
+		if size > len(data) {
+			return nil
+		}
+
+		aus = append(aus, data[:size])
+		data = data[size:]
+	}
+
+	return aus
+}
+
+// LATMPacketizer splits AAC samples into MP4A-LATM RTP payloads, one
+// access unit per payload, fragmenting the larger ones.
+type LATMPacketizer struct {
+	maxPayloadSize int
+}
+
+// Packetize splits a sample, with one or more raw or ADTS access units,
+// into RTP payloads.
+func (l *LATMPacketizer) Packetize(sample []byte) [][]byte {
+	var payloads [][]byte
+
+	for _, au := range splitADTS(sample) {
+		// PayloadLengthInfo
+		b := make([]byte, 0, len(au)/255+1+len(au))
+
+		for n := len(au); ; n -= 255 {
+			if n < 255 {
+				b = append(b, byte(n))
+				break
+			}
+
+			b = append(b, 255)
+		}
+
+		b = append(b, au...)
+
+		for len(b) > 0 {
+			n := l.maxPayloadSize
+
+			if n > len(b) {
+				n = len(b)
+			}
+
+			payloads = append(payloads, b[:n])
+			b = b[n:]
+		}
+	}
+
+	return payloads
+}
+
+// LATMDepacketizer reassembles AAC access units from MP4A-LATM RTP
+// payloads, with a single access unit per RTP packet or fragmented over
+// several of them.
+type LATMDepacketizer struct {
+	buffer   []byte
+	sequence uint16
+	started  bool
+	lost     bool
+}
+
+// Depacketize receives a RTP packet and gives a raw AAC frame when the
+// packet finishes it.
+func (l *LATMDepacketizer) Depacketize(p *Packet) ([]byte, bool) {
+	if l.started && p.Sequence != l.sequence+1 {
+		l.lost = true
+	}
+
+	l.started = true
+	l.sequence = p.Sequence
+	l.buffer = append(l.buffer, p.Payload...)
+
+	if !p.Marker {
+		return nil, false
+	}
+
+	b, lost := l.buffer, l.lost
+	l.buffer = nil
+	l.lost = false
+
+	if lost {
+		return nil, false
+	}
+
+	// PayloadLengthInfo
+	size := 0
+
+	for len(b) > 0 {
+		n := b[0]
+		b = b[1:]
+		size += int(n)
+
+		if n != 255 {
+			break
+		}
+	}
+
+	if size == 0 || size > len(b) {
+		return nil, false
+	}
+
+	return b[:size], true
+}
+
+// ParseAudioSpecificConfig gives the audio object type, sampling rate and
+// number of channels of an AAC decoder configuration.
+func ParseAudioSpecificConfig(config []byte) (int, int, int, error) {
+	r := bitReader{b: config}
+	objectType := int(r.read(5))
+	index := int(r.read(4))
+	sampleRate := 0
+
+	if index == 0x0f {
+		sampleRate = int(r.read(24))
+	} else if index < len(aacSampleRates) {
+		sampleRate = aacSampleRates[index]
+	}
+
+	channels := int(r.read(4))
+
+	if r.overflow || objectType == 0 || sampleRate == 0 {
+		return 0, 0, 0, errors.New("invalid AudioSpecificConfig")
+	}
+
+	return objectType, sampleRate, channels, nil
+}
+
+// AACConfig gives the AudioSpecificConfig announced inside the SDP fmtp
+// parameters of a mpeg4-generic stream.
+func AACConfig(fmtp string) []byte {
+	config, err := hex.DecodeString(formatParameter(fmtp, "config"))
+
+	if err != nil || len(config) == 0 {
+		return nil
+	}
+
+	return config
+}
+
+// AACFormatParameters gives the SDP fmtp parameters of a mpeg4-generic
+// AAC-hbr stream.
+func AACFormatParameters(config []byte) string {
+	return fmt.Sprintf("streamtype=5; profile-level-id=1; mode=AAC-hbr; "+
+		"sizelength=13; indexlength=3; indexdeltalength=3; config=%s",
+		hex.EncodeToString(config))
+}
+
+// LATMFormatParameters gives the SDP fmtp parameters of a MP4A-LATM stream.
+func LATMFormatParameters(objectType, sampleRate, channels int) string {
+	return fmt.Sprintf("profile-level-id=15; object=%d; cpresent=0; config=%s",
+		objectType, hex.EncodeToString(streamMuxConfig(objectType, sampleRate, channels)))
+}
+
+// NewAACPacketizer creates a new AACPacketizer whose payloads are never
+// larger than maxPayloadSize. If it is zero, DefaultMaxPayloadSize is used.
+func NewAACPacketizer(maxPayloadSize int) *AACPacketizer {
+	if maxPayloadSize <= 2+aacAUHeaderSize {
+		maxPayloadSize = DefaultMaxPayloadSize
+	}
+
+	return &AACPacketizer{
+		maxPayloadSize: maxPayloadSize,
+	}
+}
+
+// NewAACDepacketizer creates a new AACDepacketizer.
+func NewAACDepacketizer() *AACDepacketizer {
+	return &AACDepacketizer{}
+}
+
+// NewLATMDepacketizer creates a new LATMDepacketizer.
+func NewLATMDepacketizer() *LATMDepacketizer {
+	return &LATMDepacketizer{}
+}
+
+// NewLATMPacketizer creates a new LATMPacketizer whose payloads are never
+// larger than maxPayloadSize. If it is zero, DefaultMaxPayloadSize is used.
+func NewLATMPacketizer(maxPayloadSize int) *LATMPacketizer {
+	if maxPayloadSize <= 0 {
+		maxPayloadSize = DefaultMaxPayloadSize
+	}
+
+	return &LATMPacketizer{
+		maxPayloadSize: maxPayloadSize,
+	}
+}
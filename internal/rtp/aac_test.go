@@ -0,0 +1,127 @@
+//
+// Description: AAC payload format tests.
+//
+package rtp_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rsfreitas/go-rtsp/internal/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func adtsFrame(au []byte) []byte {
+	size := len(au) + 7
+
+	return append([]byte{
+		0xff, 0xf1, 0x4c, 0x80 | byte(size>>11),
+		byte(size >> 3), byte(size<<5) | 0x1f, 0xfc,
+	}, au...)
+}
+
+func TestAudioSpecificConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]byte{0x11, 0x90}, rtp.AudioSpecificConfig(rtp.AACObjectTypeLC, 48000, 2))
+	assert.Equal([]byte{0x12, 0x10}, rtp.AudioSpecificConfig(rtp.AACObjectTypeLC, 44100, 2))
+	assert.Equal("streamtype=5; profile-level-id=1; mode=AAC-hbr; sizelength=13; "+
+		"indexlength=3; indexdeltalength=3; config=1190",
+		rtp.AACFormatParameters([]byte{0x11, 0x90}))
+}
+
+func TestAACPacketizerRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	first := bytes.Repeat([]byte{0x01}, 20)
+	second := bytes.Repeat([]byte{0x02}, 30)
+	p := rtp.NewAACPacketizer(100)
+
+	// Both ADTS frames fit inside a single payload, without their headers
+	payloads := p.Packetize(append(adtsFrame(first), adtsFrame(second)...))
+	assert.Equal(1, len(payloads))
+	assert.Equal([]byte{0x00, 0x20, 0x00, 20 << 3, 0x00, 30 << 3}, payloads[0][:6])
+
+	d := rtp.NewAACDepacketizer()
+	sample, ok := d.Depacketize(&rtp.Packet{Marker: true, Payload: payloads[0]})
+	assert.True(ok)
+	assert.Equal(append(append([]byte(nil), first...), second...), sample)
+
+	// A larger access unit is fragmented
+	large := bytes.Repeat([]byte{0x03}, 250)
+	payloads = p.Packetize(large)
+	assert.Equal(3, len(payloads))
+
+	for i, payload := range payloads {
+		assert.True(len(payload) <= 100)
+		sample, ok = d.Depacketize(&rtp.Packet{
+			Marker:   i == len(payloads)-1,
+			Sequence: uint16(i + 1),
+			Payload:  payload,
+		})
+
+		assert.Equal(i == len(payloads)-1, ok)
+	}
+
+	assert.Equal(large, sample)
+}
+
+func TestLATMPacketizer(t *testing.T) {
+	assert := assert.New(t)
+
+	au := bytes.Repeat([]byte{0x04}, 300)
+	payloads := rtp.NewLATMPacketizer(0).Packetize(adtsFrame(au))
+
+	assert.Equal(1, len(payloads))
+	assert.Equal([]byte{255, 45}, payloads[0][:2])
+	assert.Equal(au, payloads[0][2:])
+}
+
+func TestAACDepacketizerFrames(t *testing.T) {
+	assert := assert.New(t)
+
+	first := bytes.Repeat([]byte{0x01}, 20)
+	second := bytes.Repeat([]byte{0x02}, 30)
+	payloads := rtp.NewAACPacketizer(100).Packetize(append(adtsFrame(first), adtsFrame(second)...))
+
+	frames := rtp.NewAACDepacketizer().DepacketizeFrames(&rtp.Packet{Marker: true, Payload: payloads[0]})
+	assert.Equal([][]byte{first, second}, frames)
+}
+
+func TestParseAudioSpecificConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	objectType, sampleRate, channels, err := rtp.ParseAudioSpecificConfig(
+		rtp.AACConfig(rtp.AACFormatParameters([]byte{0x11, 0x90})))
+	assert.Nil(err)
+	assert.Equal(rtp.AACObjectTypeLC, objectType)
+	assert.Equal(48000, sampleRate)
+	assert.Equal(2, channels)
+
+	_, _, _, err = rtp.ParseAudioSpecificConfig([]byte{0x11})
+	assert.NotNil(err)
+}
+
+func TestLATMDepacketizer(t *testing.T) {
+	assert := assert.New(t)
+
+	au := bytes.Repeat([]byte{0x04}, 300)
+	payloads := rtp.NewLATMPacketizer(100).Packetize(au)
+	d := rtp.NewLATMDepacketizer()
+
+	var (
+		sample []byte
+		ok     bool
+	)
+
+	for i, payload := range payloads {
+		sample, ok = d.Depacketize(&rtp.Packet{
+			Marker:   i == len(payloads)-1,
+			Sequence: uint16(i),
+			Payload:  payload,
+		})
+	}
+
+	assert.True(ok)
+	assert.Equal(au, sample)
+}
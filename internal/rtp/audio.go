@@ -0,0 +1,138 @@
+//
+// Description: G.711, G.722 (RFC 3551) and Opus (RFC 7587) payload formats.
+//
+package rtp
+
+import "strings"
+
+const (
+	// Static payload types (RFC 3551 section 6)
+	PCMUPayloadType = 0
+	PCMAPayloadType = 8
+	G722PayloadType = 9
+
+	// G.711 and G.722 use an 8000 Hz RTP clock. G.722 samples at 16000 Hz,
+	// but its clock rate was wrongly registered as 8000 Hz, which is kept
+	// for compatibility (RFC 3551 section 4.5.2).
+	PCMClockRate = 8000
+
+	// OpusClockRate is always used by Opus, regardless of the real
+	// sampling rate.
+	OpusClockRate = 48000
+
+	// pcmFrameSize is 20ms of G.711 or G.722 audio.
+	pcmFrameSize = 160
+)
+
+// PCMPacketizer splits G.711 (PCMU and PCMA) or G.722 audio into RTP
+// payloads of 20ms. In both formats a byte lasts one RTP clock unit.
+type PCMPacketizer struct {
+	frameSize int
+}
+
+// Packetize splits a sample, with any amount of audio, into RTP payloads.
+func (p *PCMPacketizer) Packetize(sample []byte) [][]byte {
+	var payloads [][]byte
+
+	for len(sample) > 0 {
+		n := p.frameSize
+
+		if n > len(sample) {
+			n = len(sample)
+		}
+
+		payloads = append(payloads, sample[:n])
+		sample = sample[n:]
+	}
+
+	return payloads
+}
+
+// Duration gives how long a payload lasts, in RTP clock rate units.
+func (p *PCMPacketizer) Duration(payload []byte) uint32 {
+	return uint32(len(payload))
+}
+
+// OpusPacketizer sends Opus packets as RTP payloads. Each sample must be a
+// single Opus packet, as given by the encoder.
+type OpusPacketizer struct{}
+
+// Packetize gives the Opus packet as the RTP payload.
+func (o *OpusPacketizer) Packetize(sample []byte) [][]byte {
+	return [][]byte{sample}
+}
+
+// Duration gives how long an Opus packet lasts, in RTP clock rate units,
+// using its TOC byte (RFC 6716 section 3.1).
+func (o *OpusPacketizer) Duration(payload []byte) uint32 {
+	if len(payload) == 0 {
+		return 0
+	}
+
+	var (
+		config = payload[0] >> 3
+		frames uint32
+		frame  uint32
+	)
+
+	switch {
+	case config < 12:
+		// SILK: 10, 20, 40 or 60ms
+		frame = []uint32{480, 960, 1920, 2880}[config%4]
+
+	case config < 16:
+		// Hybrid: 10 or 20ms
+		frame = []uint32{480, 960}[config%2]
+
+	default:
+		// CELT: 2.5, 5, 10 or 20ms
+		frame = []uint32{120, 240, 480, 960}[config%4]
+	}
+
+	switch payload[0] & 0x03 {
+	case 0:
+		frames = 1
+
+	case 1, 2:
+		frames = 2
+
+	default:
+		if len(payload) < 2 {
+			return 0
+		}
+
+		frames = uint32(payload[1] & 0x3f)
+	}
+
+	return frames * frame
+}
+
+// OpusFormatParameters gives the SDP fmtp parameters of an Opus stream.
+func OpusFormatParameters(channels int) string {
+	parameters := []string{"minptime=10", "useinbandfec=1"}
+
+	if channels > 1 {
+		parameters = append(parameters, "stereo=1", "sprop-stereo=1")
+	}
+
+	return strings.Join(parameters, "; ")
+}
+
+// NewPCMPacketizer creates a new PCMPacketizer whose payloads are never
+// larger than maxPayloadSize. If it is zero, 20ms payloads are used.
+func NewPCMPacketizer(maxPayloadSize int) *PCMPacketizer {
+	frameSize := pcmFrameSize
+
+	if maxPayloadSize > 0 && maxPayloadSize < frameSize {
+		frameSize = maxPayloadSize
+	}
+
+	return &PCMPacketizer{
+		frameSize: frameSize,
+	}
+}
+
+// NewOpusPacketizer creates a new OpusPacketizer.
+func NewOpusPacketizer() *OpusPacketizer {
+	return &OpusPacketizer{}
+}
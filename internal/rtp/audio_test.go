@@ -0,0 +1,56 @@
+//
+// Description: Audio payload format tests.
+//
+package rtp_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rsfreitas/go-rtsp/internal/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPCMPacketizer(t *testing.T) {
+	assert := assert.New(t)
+
+	p := rtp.NewPCMPacketizer(0)
+	payloads := p.Packetize(bytes.Repeat([]byte{0xff}, 400))
+
+	// 20ms frames, the last one with what was left
+	assert.Equal(3, len(payloads))
+	assert.Equal(uint32(160), p.Duration(payloads[0]))
+	assert.Equal(uint32(80), p.Duration(payloads[2]))
+
+	payloads = rtp.NewPCMPacketizer(100).Packetize(bytes.Repeat([]byte{0xff}, 160))
+	assert.Equal(2, len(payloads))
+}
+
+func TestOpusDuration(t *testing.T) {
+	assert := assert.New(t)
+
+	p := rtp.NewOpusPacketizer()
+
+	for _, test := range []struct {
+		packet   []byte
+		duration uint32
+	}{
+		// SILK 20ms, a single frame
+		{[]byte{0x08, 0x00}, 960},
+		// SILK 60ms, two frames
+		{[]byte{0x19, 0x00}, 5760},
+		// Hybrid 10ms, a single frame
+		{[]byte{0x60, 0x00}, 480},
+		// CELT 2.5ms, code 3 with 4 frames
+		{[]byte{0x83, 0x04}, 480},
+		// CELT 20ms, a single frame
+		{[]byte{0xf8, 0x00}, 960},
+	} {
+		payloads := p.Packetize(test.packet)
+		assert.Equal(1, len(payloads))
+		assert.Equal(test.duration, p.Duration(payloads[0]))
+	}
+
+	assert.Equal("minptime=10; useinbandfec=1; stereo=1; sprop-stereo=1",
+		rtp.OpusFormatParameters(2))
+}
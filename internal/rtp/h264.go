@@ -0,0 +1,449 @@
+//
+// Description: H.264 RTP payload format (RFC 6184).
+//
+package rtp
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	h264NalIDR   = 5
+	h264NalSPS   = 7
+	h264NalPPS   = 8
+	h264NalSTAPA = 24
+	h264NalFUA   = 28
+
+	// DefaultMaxPayloadSize is the maximum RTP payload size used when none
+	// is given. It keeps packets, with their IP/UDP/RTP headers, below the
+	// usual 1500 bytes ethernet MTU.
+	DefaultMaxPayloadSize = 1400
+)
+
+// H264Packetizer splits H.264 access units into RTP payloads using single
+// NAL unit packets, STAP-A and FU-A (packetization-mode=1).
+type H264Packetizer struct {
+	maxPayloadSize int
+
+	lock sync.Mutex
+	sps  []byte
+	pps  []byte
+}
+
+// Packetize splits an access unit, in Annex-B or AVCC format, into RTP
+// payloads.
+func (h *H264Packetizer) Packetize(sample []byte) [][]byte {
+	nals := SplitNALUnits(sample)
+	h.saveParameterSets(nals)
+
+	var (
+		payloads [][]byte
+		stap     [][]byte
+		stapSize = 1
+	)
+
+	flush := func() {
+		if len(stap) == 1 {
+			payloads = append(payloads, stap[0])
+		} else if len(stap) > 1 {
+			payloads = append(payloads, h264Aggregate(stap, stapSize))
+		}
+
+		stap = nil
+		stapSize = 1
+	}
+
+	for _, nal := range nals {
+		if len(nal) > h.maxPayloadSize {
+			flush()
+			payloads = append(payloads, h264Fragment(nal, h.maxPayloadSize)...)
+			continue
+		}
+
+		if stapSize+2+len(nal) > h.maxPayloadSize {
+			flush()
+
+			// It fits alone, but not inside an aggregation packet
+			if stapSize+2+len(nal) > h.maxPayloadSize {
+				payloads = append(payloads, nal)
+				continue
+			}
+		}
+
+		stap = append(stap, nal)
+		stapSize += 2 + len(nal)
+	}
+
+	flush()
+
+	return payloads
+}
+
+// saveParameterSets keeps the last SPS and PPS found inside the stream.
+func (h *H264Packetizer) saveParameterSets(nals [][]byte) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for _, nal := range nals {
+		switch nal[0] & 0x1f {
+		case h264NalSPS:
+			h.sps = append([]byte(nil), nal...)
+
+		case h264NalPPS:
+			h.pps = append([]byte(nil), nal...)
+		}
+	}
+}
+
+// ParameterSets gives the last SPS and PPS found inside the stream.
+func (h *H264Packetizer) ParameterSets() ([]byte, []byte) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.sps, h.pps
+}
+
+// h264Aggregate puts several NAL units inside a STAP-A packet.
+func h264Aggregate(nals [][]byte, size int) []byte {
+	b := make([]byte, 1, size)
+	var nri byte
+
+	for _, nal := range nals {
+		if n := nal[0] & 0x60; n > nri {
+			nri = n
+		}
+
+		b = append(b, byte(len(nal)>>8), byte(len(nal)))
+		b = append(b, nal...)
+	}
+
+	b[0] = nri | h264NalSTAPA
+
+	return b
+}
+
+// h264Fragment splits a NAL unit into FU-A packets.
+func h264Fragment(nal []byte, maxPayloadSize int) [][]byte {
+	var payloads [][]byte
+
+	indicator := nal[0]&0xe0 | h264NalFUA
+	nalType := nal[0] & 0x1f
+	data := nal[1:]
+	chunk := maxPayloadSize - 2
+
+	for start := true; len(data) > 0; start = false {
+		n := chunk
+
+		if n > len(data) {
+			n = len(data)
+		}
+
+		header := nalType
+
+		if start {
+			header |= 0x80
+		}
+
+		if n == len(data) {
+			header |= 0x40
+		}
+
+		payload := make([]byte, 2+n)
+		payload[0] = indicator
+		payload[1] = header
+		copy(payload[2:], data[:n])
+		payloads = append(payloads, payload)
+		data = data[n:]
+	}
+
+	return payloads
+}
+
+// SplitNALUnits splits an access unit into its NAL units. The access unit
+// may use the Annex-B format (start codes) or the AVCC format (4 bytes
+// length prefixes). If none of them is detected, the whole access unit is
+// considered a single NAL unit.
+func SplitNALUnits(au []byte) [][]byte {
+	if hasStartCode(au) {
+		return splitAnnexB(au)
+	}
+
+	if nals, ok := splitAVCC(au); ok {
+		return nals
+	}
+
+	if len(au) == 0 {
+		return nil
+	}
+
+	return [][]byte{au}
+}
+
+func hasStartCode(b []byte) bool {
+	return (len(b) >= 3 && b[0] == 0 && b[1] == 0 && b[2] == 1) ||
+		(len(b) >= 4 && b[0] == 0 && b[1] == 0 && b[2] == 0 && b[3] == 1)
+}
+
+func splitAnnexB(b []byte) [][]byte {
+	var (
+		nals  [][]byte
+		start = -1
+		zeros int
+	)
+
+	for i := 0; i < len(b); i++ {
+		switch {
+		case b[i] == 0:
+			zeros++
+			continue
+
+		case b[i] == 1 && zeros >= 2:
+			if start >= 0 {
+				end := i - zeros
+
+				if end > start {
+					nals = append(nals, b[start:end])
+				}
+			}
+
+			start = i + 1
+		}
+
+		zeros = 0
+	}
+
+	if start >= 0 && start < len(b) {
+		nals = append(nals, b[start:])
+	}
+
+	return nals
+}
+
+func splitAVCC(b []byte) ([][]byte, bool) {
+	var nals [][]byte
+
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, false
+		}
+
+		n := int(binary.BigEndian.Uint32(b))
+
+		if n == 0 || n > len(b)-4 {
+			return nil, false
+		}
+
+		nals = append(nals, b[4:4+n])
+		b = b[4+n:]
+	}
+
+	return nals, len(nals) > 0
+}
+
+// H264FormatParameters gives the SDP fmtp parameters of a H.264 stream
+// using its SPS and PPS.
+func H264FormatParameters(sps, pps []byte) string {
+	parameters := []string{"packetization-mode=1"}
+
+	if len(sps) >= 4 {
+		parameters = append(parameters, "profile-level-id="+
+			strings.ToUpper(hex.EncodeToString(sps[1:4])))
+	}
+
+	if len(sps) > 0 && len(pps) > 0 {
+		parameters = append(parameters, fmt.Sprintf("sprop-parameter-sets=%s,%s",
+			base64.StdEncoding.EncodeToString(sps),
+			base64.StdEncoding.EncodeToString(pps)))
+	}
+
+	return strings.Join(parameters, "; ")
+}
+
+// H264Depacketizer reassembles H.264 access units from RTP packets using
+// single NAL unit packets, STAP-A and FU-A.
+type H264Depacketizer struct {
+	nals      [][]byte
+	fragment  []byte
+	timestamp uint32
+	sequence  uint16
+	started   bool
+	lost      bool
+}
+
+// Depacketize receives a RTP packet and gives a complete access unit, in
+// Annex-B format, when the packet finishes it. Access units with lost
+// packets are discarded.
+func (h *H264Depacketizer) Depacketize(p *Packet) ([]byte, bool) {
+	if h.started {
+		if p.Timestamp != h.timestamp {
+			// The previous access unit ended without a marker bit
+			h.reset()
+		}
+
+		if p.Sequence != h.sequence+1 {
+			h.lost = true
+			h.fragment = nil
+		}
+	}
+
+	h.started = true
+	h.timestamp = p.Timestamp
+	h.sequence = p.Sequence
+
+	if len(p.Payload) == 0 {
+		h.lost = true
+	} else {
+		h.parse(p.Payload)
+	}
+
+	if !p.Marker {
+		return nil, false
+	}
+
+	nals, lost := h.nals, h.lost
+	h.reset()
+
+	if lost || len(nals) == 0 {
+		return nil, false
+	}
+
+	return joinAnnexB(nals), true
+}
+
+// parse extracts NAL units from a RTP payload.
+func (h *H264Depacketizer) parse(payload []byte) {
+	switch payload[0] & 0x1f {
+	case h264NalSTAPA:
+		b := payload[1:]
+
+		for len(b) >= 2 {
+			n := int(binary.BigEndian.Uint16(b))
+
+			if n == 0 || n > len(b)-2 {
+				h.lost = true
+				return
+			}
+
+			h.nals = append(h.nals, b[2:2+n])
+			b = b[2+n:]
+		}
+
+	case h264NalFUA:
+		if len(payload) < 2 {
+			h.lost = true
+			return
+		}
+
+		fu := payload[1]
+
+		if fu&0x80 != 0 {
+			// Rebuilds the original NAL unit header
+			h.fragment = []byte{payload[0]&0xe0 | fu&0x1f}
+		} else if h.fragment == nil {
+			// We missed the fragment start
+			h.lost = true
+			return
+		}
+
+		h.fragment = append(h.fragment, payload[2:]...)
+
+		if fu&0x40 != 0 {
+			h.nals = append(h.nals, h.fragment)
+			h.fragment = nil
+		}
+
+	default:
+		h.nals = append(h.nals, payload)
+	}
+}
+
+func (h *H264Depacketizer) reset() {
+	h.nals = nil
+	h.fragment = nil
+	h.lost = false
+}
+
+// H264KeyFrame tells if a RTP payload starts a key frame, carrying an IDR
+// picture (or its beginning) or the parameter sets sent before it.
+func H264KeyFrame(payload []byte) bool {
+	if len(payload) == 0 {
+		return false
+	}
+
+	key := func(nalType byte) bool {
+		return nalType == h264NalIDR || nalType == h264NalSPS || nalType == h264NalPPS
+	}
+
+	switch payload[0] & 0x1f {
+	case h264NalSTAPA:
+		b := payload[1:]
+
+		for len(b) > 2 {
+			n := int(binary.BigEndian.Uint16(b))
+
+			if n == 0 || n > len(b)-2 {
+				return false
+			}
+
+			if key(b[2] & 0x1f) {
+				return true
+			}
+
+			b = b[2+n:]
+		}
+
+		return false
+
+	case h264NalFUA:
+		return len(payload) > 1 && payload[1]&0x80 != 0 && key(payload[1]&0x1f)
+	}
+
+	return key(payload[0] & 0x1f)
+}
+
+// H264ParameterSets gives the SPS and PPS announced inside the SDP fmtp
+// parameters of a H.264 stream.
+func H264ParameterSets(fmtp string) ([]byte, []byte) {
+	var sps, pps []byte
+
+	for _, s := range strings.Split(formatParameter(fmtp, "sprop-parameter-sets"), ",") {
+		nal, err := base64.StdEncoding.DecodeString(s)
+
+		if err != nil || len(nal) == 0 {
+			continue
+		}
+
+		switch nal[0] & 0x1f {
+		case h264NalSPS:
+			sps = nal
+
+		case h264NalPPS:
+			pps = nal
+		}
+	}
+
+	return sps, pps
+}
+
+// NewH264Packetizer creates a new H264Packetizer whose payloads are never
+// larger than maxPayloadSize. If it is zero (or too small to hold a FU-A
+// packet), DefaultMaxPayloadSize is used.
+func NewH264Packetizer(maxPayloadSize int) *H264Packetizer {
+	if maxPayloadSize <= 2 {
+		maxPayloadSize = DefaultMaxPayloadSize
+	}
+
+	return &H264Packetizer{
+		maxPayloadSize: maxPayloadSize,
+	}
+}
+
+// NewH264Depacketizer creates a new H264Depacketizer.
+func NewH264Depacketizer() *H264Depacketizer {
+	return &H264Depacketizer{}
+}
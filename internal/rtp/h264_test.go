@@ -0,0 +1,159 @@
+//
+// Description: H.264 payload format tests.
+//
+package rtp_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rsfreitas/go-rtsp/internal/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testSPS = []byte{0x67, 0x42, 0xc0, 0x1f, 0xda, 0x01, 0x40, 0x16, 0xe8}
+	testPPS = []byte{0x68, 0xce, 0x3c, 0x80}
+)
+
+func TestSplitNALUnits(t *testing.T) {
+	assert := assert.New(t)
+
+	annexB := append([]byte{0, 0, 0, 1}, testSPS...)
+	annexB = append(annexB, 0, 0, 1)
+	annexB = append(annexB, testPPS...)
+	assert.Equal([][]byte{testSPS, testPPS}, rtp.SplitNALUnits(annexB))
+
+	avcc := append([]byte{0, 0, 0, byte(len(testSPS))}, testSPS...)
+	avcc = append(avcc, 0, 0, 0, byte(len(testPPS)))
+	avcc = append(avcc, testPPS...)
+	assert.Equal([][]byte{testSPS, testPPS}, rtp.SplitNALUnits(avcc))
+}
+
+func TestH264PacketizerSTAPA(t *testing.T) {
+	assert := assert.New(t)
+
+	p := rtp.NewH264Packetizer(100)
+	idr := []byte{0x65, 1, 2, 3}
+	au := append([]byte{0, 0, 0, 1}, testSPS...)
+	au = append(au, 0, 0, 0, 1)
+	au = append(au, testPPS...)
+	au = append(au, 0, 0, 0, 1)
+	au = append(au, idr...)
+
+	payloads := p.Packetize(au)
+	assert.Equal(1, len(payloads))
+	assert.Equal(byte(0x60|24), payloads[0][0])
+	assert.Equal([]byte{0, byte(len(testSPS))}, payloads[0][1:3])
+	assert.Equal(testSPS, payloads[0][3:3+len(testSPS)])
+
+	sps, pps := p.ParameterSets()
+	assert.Equal(testSPS, sps)
+	assert.Equal(testPPS, pps)
+}
+
+func TestH264PacketizerFUA(t *testing.T) {
+	assert := assert.New(t)
+
+	p := rtp.NewH264Packetizer(100)
+	nal := append([]byte{0x65}, bytes.Repeat([]byte{0xaa}, 250)...)
+	payloads := p.Packetize(append([]byte{0, 0, 1}, nal...))
+
+	assert.Equal(3, len(payloads))
+	var data []byte
+
+	for i, payload := range payloads {
+		assert.True(len(payload) <= 100)
+		assert.Equal(byte(0x60|28), payload[0])
+		assert.Equal(byte(5), payload[1]&0x1f)
+		assert.Equal(i == 0, payload[1]&0x80 != 0)
+		assert.Equal(i == len(payloads)-1, payload[1]&0x40 != 0)
+		data = append(data, payload[2:]...)
+	}
+
+	assert.Equal(nal[1:], data)
+}
+
+func TestH264FormatParameters(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("packetization-mode=1; profile-level-id=42C01F; sprop-parameter-sets=Z0LAH9oBQBbo,aM48gA==",
+		rtp.H264FormatParameters(testSPS, testPPS))
+}
+
+func TestH264DepacketizerRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	idr := append([]byte{0x65}, bytes.Repeat([]byte{0xbb}, 250)...)
+	au := append([]byte{0, 0, 0, 1}, testSPS...)
+	au = append(au, 0, 0, 0, 1)
+	au = append(au, testPPS...)
+	au = append(au, 0, 0, 0, 1)
+	au = append(au, idr...)
+
+	payloads := rtp.NewH264Packetizer(100).Packetize(au)
+	d := rtp.NewH264Depacketizer()
+
+	var (
+		sample []byte
+		ok     bool
+	)
+
+	for i, payload := range payloads {
+		sample, ok = d.Depacketize(&rtp.Packet{
+			Marker:   i == len(payloads)-1,
+			Sequence: uint16(i),
+			Payload:  payload,
+		})
+
+		assert.Equal(i == len(payloads)-1, ok)
+	}
+
+	assert.Equal(au, sample)
+
+	// A lost fragment discards the access unit
+	d = rtp.NewH264Depacketizer()
+	d.Depacketize(&rtp.Packet{Sequence: 0, Timestamp: 1, Payload: payloads[0]})
+	_, ok = d.Depacketize(&rtp.Packet{Marker: true, Sequence: 2, Timestamp: 1, Payload: payloads[len(payloads)-1]})
+	assert.False(ok)
+}
+
+func TestH264ParameterSets(t *testing.T) {
+	assert := assert.New(t)
+
+	sps, pps := rtp.H264ParameterSets(rtp.H264FormatParameters(testSPS, testPPS))
+	assert.Equal(testSPS, sps)
+	assert.Equal(testPPS, pps)
+
+	sps, pps = rtp.H264ParameterSets("packetization-mode=1")
+	assert.Nil(sps)
+	assert.Nil(pps)
+}
+
+func TestH264KeyFrame(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(rtp.H264KeyFrame([]byte{0x65, 0x88}))
+	assert.True(rtp.H264KeyFrame(testSPS))
+	assert.False(rtp.H264KeyFrame([]byte{0x41, 0x9a}))
+	assert.False(rtp.H264KeyFrame(nil))
+
+	// STAP-A with the parameter sets
+	payloads := rtp.NewH264Packetizer(0).Packetize(append(append([]byte{0, 0, 0, 1}, testSPS...),
+		append([]byte{0, 0, 0, 1}, testPPS...)...))
+
+	assert.Len(payloads, 1)
+	assert.True(rtp.H264KeyFrame(payloads[0]))
+
+	// FU-A: only the first fragment of an IDR picture starts a key frame
+	idr := make([]byte, 3000)
+	idr[0] = 0x65
+	payloads = rtp.NewH264Packetizer(1000).Packetize(append([]byte{0, 0, 0, 1}, idr...))
+
+	assert.True(rtp.H264KeyFrame(payloads[0]))
+	assert.False(rtp.H264KeyFrame(payloads[1]))
+
+	idr[0] = 0x41
+	payloads = rtp.NewH264Packetizer(1000).Packetize(append([]byte{0, 0, 0, 1}, idr...))
+	assert.False(rtp.H264KeyFrame(payloads[0]))
+}
@@ -0,0 +1,411 @@
+//
+// Description: H.265/HEVC RTP payload format (RFC 7798).
+//
+package rtp
+
+import (
+	"encoding/base64"
+	"strings"
+	"sync"
+)
+
+const (
+	// IRAP (key frame) pictures have NAL unit types from 16 to 23
+	h265NalBLAWLP  = 16
+	h265NalIRAPMax = 23
+	h265NalVPS     = 32
+	h265NalSPS     = 33
+	h265NalPPS     = 34
+	h265NalAP      = 48
+	h265NalFU      = 49
+
+	h265NalHeaderSize = 2
+)
+
+func h265NalType(nal []byte) byte {
+	return (nal[0] >> 1) & 0x3f
+}
+
+// H265Packetizer splits H.265 access units into RTP payloads using single
+// NAL unit packets, aggregation packets (AP) and fragmentation units (FU).
+type H265Packetizer struct {
+	maxPayloadSize int
+
+	lock sync.Mutex
+	vps  []byte
+	sps  []byte
+	pps  []byte
+}
+
+// Packetize splits an access unit, in Annex-B or length prefixed format,
+// into RTP payloads.
+func (h *H265Packetizer) Packetize(sample []byte) [][]byte {
+	var (
+		payloads [][]byte
+		ap       [][]byte
+		apSize   = h265NalHeaderSize
+	)
+
+	nals := validH265NALUnits(SplitNALUnits(sample))
+	h.saveParameterSets(nals)
+
+	flush := func() {
+		if len(ap) == 1 {
+			payloads = append(payloads, ap[0])
+		} else if len(ap) > 1 {
+			payloads = append(payloads, h265Aggregate(ap, apSize))
+		}
+
+		ap = nil
+		apSize = h265NalHeaderSize
+	}
+
+	for _, nal := range nals {
+		if len(nal) > h.maxPayloadSize {
+			flush()
+			payloads = append(payloads, h265Fragment(nal, h.maxPayloadSize)...)
+			continue
+		}
+
+		if apSize+2+len(nal) > h.maxPayloadSize {
+			flush()
+
+			// It fits alone, but not inside an aggregation packet
+			if apSize+2+len(nal) > h.maxPayloadSize {
+				payloads = append(payloads, nal)
+				continue
+			}
+		}
+
+		ap = append(ap, nal)
+		apSize += 2 + len(nal)
+	}
+
+	flush()
+
+	return payloads
+}
+
+// saveParameterSets keeps the last VPS, SPS and PPS found inside the
+// stream.
+func (h *H265Packetizer) saveParameterSets(nals [][]byte) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for _, nal := range nals {
+		switch h265NalType(nal) {
+		case h265NalVPS:
+			h.vps = append([]byte(nil), nal...)
+
+		case h265NalSPS:
+			h.sps = append([]byte(nil), nal...)
+
+		case h265NalPPS:
+			h.pps = append([]byte(nil), nal...)
+		}
+	}
+}
+
+// ParameterSets gives the last VPS, SPS and PPS found inside the stream.
+func (h *H265Packetizer) ParameterSets() ([]byte, []byte, []byte) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.vps, h.sps, h.pps
+}
+
+// validH265NALUnits discards NAL units too short to hold a NAL header.
+func validH265NALUnits(nals [][]byte) [][]byte {
+	valid := nals[:0]
+
+	for _, nal := range nals {
+		if len(nal) >= h265NalHeaderSize {
+			valid = append(valid, nal)
+		}
+	}
+
+	return valid
+}
+
+// h265Aggregate puts several NAL units inside an aggregation packet. Its
+// header uses the lowest LayerId and TID of the aggregated units, and the F
+// bit is set if any of them has it.
+func h265Aggregate(nals [][]byte, size int) []byte {
+	b := make([]byte, h265NalHeaderSize, size)
+	var (
+		forbidden byte
+		layerID   = 0x3f
+		tid       = 0x07
+	)
+
+	for _, nal := range nals {
+		forbidden |= nal[0] & 0x80
+
+		if l := int(nal[0]&0x01)<<5 | int(nal[1]>>3); l < layerID {
+			layerID = l
+		}
+
+		if t := int(nal[1] & 0x07); t < tid {
+			tid = t
+		}
+
+		b = append(b, byte(len(nal)>>8), byte(len(nal)))
+		b = append(b, nal...)
+	}
+
+	b[0] = forbidden | h265NalAP<<1 | byte(layerID>>5)
+	b[1] = byte(layerID&0x1f)<<3 | byte(tid)
+
+	return b
+}
+
+// h265Fragment splits a NAL unit into fragmentation units.
+func h265Fragment(nal []byte, maxPayloadSize int) [][]byte {
+	var payloads [][]byte
+
+	nalType := h265NalType(nal)
+	header0 := nal[0]&0x81 | h265NalFU<<1
+	header1 := nal[1]
+	data := nal[h265NalHeaderSize:]
+	chunk := maxPayloadSize - 3
+
+	for start := true; len(data) > 0; start = false {
+		n := chunk
+
+		if n > len(data) {
+			n = len(data)
+		}
+
+		fu := nalType
+
+		if start {
+			fu |= 0x80
+		}
+
+		if n == len(data) {
+			fu |= 0x40
+		}
+
+		payload := make([]byte, 3+n)
+		payload[0] = header0
+		payload[1] = header1
+		payload[2] = fu
+		copy(payload[3:], data[:n])
+		payloads = append(payloads, payload)
+		data = data[n:]
+	}
+
+	return payloads
+}
+
+// H265Depacketizer reassembles H.265 access units from RTP packets.
+type H265Depacketizer struct {
+	nals      [][]byte
+	fragment  []byte
+	timestamp uint32
+	sequence  uint16
+	started   bool
+	lost      bool
+}
+
+// Depacketize receives a RTP packet and gives a complete access unit, in
+// Annex-B format, when the packet finishes it. Access units with lost
+// packets are discarded.
+func (h *H265Depacketizer) Depacketize(p *Packet) ([]byte, bool) {
+	if h.started {
+		if p.Timestamp != h.timestamp {
+			// The previous access unit ended without a marker bit
+			h.reset()
+		}
+
+		if p.Sequence != h.sequence+1 {
+			h.lost = true
+			h.fragment = nil
+		}
+	}
+
+	h.started = true
+	h.timestamp = p.Timestamp
+	h.sequence = p.Sequence
+
+	if len(p.Payload) < h265NalHeaderSize {
+		h.lost = true
+	} else {
+		h.parse(p.Payload)
+	}
+
+	if !p.Marker {
+		return nil, false
+	}
+
+	nals, lost := h.nals, h.lost
+	h.reset()
+
+	if lost || len(nals) == 0 {
+		return nil, false
+	}
+
+	return joinAnnexB(nals), true
+}
+
+// parse extracts NAL units from a RTP payload.
+func (h *H265Depacketizer) parse(payload []byte) {
+	switch h265NalType(payload) {
+	case h265NalAP:
+		b := payload[h265NalHeaderSize:]
+
+		for len(b) >= 2 {
+			n := int(b[0])<<8 | int(b[1])
+
+			if n == 0 || n > len(b)-2 {
+				h.lost = true
+				return
+			}
+
+			h.nals = append(h.nals, b[2:2+n])
+			b = b[2+n:]
+		}
+
+	case h265NalFU:
+		if len(payload) < 3 {
+			h.lost = true
+			return
+		}
+
+		fu := payload[2]
+
+		if fu&0x80 != 0 {
+			// Rebuilds the original NAL unit header
+			h.fragment = []byte{
+				payload[0]&0x81 | (fu&0x3f)<<1,
+				payload[1],
+			}
+		} else if h.fragment == nil {
+			// We missed the fragment start
+			h.lost = true
+			return
+		}
+
+		h.fragment = append(h.fragment, payload[3:]...)
+
+		if fu&0x40 != 0 {
+			h.nals = append(h.nals, h.fragment)
+			h.fragment = nil
+		}
+
+	default:
+		h.nals = append(h.nals, payload)
+	}
+}
+
+func (h *H265Depacketizer) reset() {
+	h.nals = nil
+	h.fragment = nil
+	h.lost = false
+}
+
+// joinAnnexB puts NAL units together, as an access unit, in Annex-B
+// format.
+func joinAnnexB(nals [][]byte) []byte {
+	var b []byte
+
+	for _, nal := range nals {
+		b = append(b, 0, 0, 0, 1)
+		b = append(b, nal...)
+	}
+
+	return b
+}
+
+// H265FormatParameters gives the SDP fmtp parameters of a H.265 stream
+// using its VPS, SPS and PPS.
+func H265FormatParameters(vps, sps, pps []byte) string {
+	var parameters []string
+
+	for _, p := range []struct {
+		name string
+		nal  []byte
+	}{
+		{"sprop-vps", vps},
+		{"sprop-sps", sps},
+		{"sprop-pps", pps},
+	} {
+		if len(p.nal) > 0 {
+			parameters = append(parameters, p.name+"="+
+				base64.StdEncoding.EncodeToString(p.nal))
+		}
+	}
+
+	return strings.Join(parameters, "; ")
+}
+
+// H265KeyFrame tells if a RTP payload starts a key frame, carrying an IRAP
+// picture (or its beginning) or the parameter sets sent before it.
+func H265KeyFrame(payload []byte) bool {
+	if len(payload) < h265NalHeaderSize {
+		return false
+	}
+
+	key := func(nalType byte) bool {
+		return (nalType >= h265NalBLAWLP && nalType <= h265NalIRAPMax) ||
+			(nalType >= h265NalVPS && nalType <= h265NalPPS)
+	}
+
+	switch h265NalType(payload) {
+	case h265NalAP:
+		b := payload[h265NalHeaderSize:]
+
+		for len(b) > 2 {
+			n := int(b[0])<<8 | int(b[1])
+
+			if n == 0 || n > len(b)-2 {
+				return false
+			}
+
+			if key(h265NalType(b[2:])) {
+				return true
+			}
+
+			b = b[2+n:]
+		}
+
+		return false
+
+	case h265NalFU:
+		return len(payload) > 2 && payload[2]&0x80 != 0 && key(payload[2]&0x3f)
+	}
+
+	return key(h265NalType(payload))
+}
+
+// H265ParameterSets gives the VPS, SPS and PPS announced inside the SDP
+// fmtp parameters of a H.265 stream.
+func H265ParameterSets(fmtp string) ([]byte, []byte, []byte) {
+	var nals [3][]byte
+
+	for i, name := range []string{"sprop-vps", "sprop-sps", "sprop-pps"} {
+		if nal, err := base64.StdEncoding.DecodeString(formatParameter(fmtp, name)); err == nil && len(nal) > 0 {
+			nals[i] = nal
+		}
+	}
+
+	return nals[0], nals[1], nals[2]
+}
+
+// NewH265Packetizer creates a new H265Packetizer whose payloads are never
+// larger than maxPayloadSize. If it is zero (or too small to hold a FU),
+// DefaultMaxPayloadSize is used.
+func NewH265Packetizer(maxPayloadSize int) *H265Packetizer {
+	if maxPayloadSize <= 3 {
+		maxPayloadSize = DefaultMaxPayloadSize
+	}
+
+	return &H265Packetizer{
+		maxPayloadSize: maxPayloadSize,
+	}
+}
+
+// NewH265Depacketizer creates a new H265Depacketizer.
+func NewH265Depacketizer() *H265Depacketizer {
+	return &H265Depacketizer{}
+}
@@ -0,0 +1,92 @@
+//
+// Description: H.265 payload format tests.
+//
+package rtp_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rsfreitas/go-rtsp/internal/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestH265PacketizerRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	vps := []byte{0x40, 0x01, 0x0c}
+	sps := []byte{0x42, 0x01, 0x01}
+	pps := []byte{0x44, 0x01, 0xc1}
+	idr := append([]byte{0x26, 0x01}, bytes.Repeat([]byte{0xaa}, 300)...)
+
+	var au []byte
+
+	for _, nal := range [][]byte{vps, sps, pps, idr} {
+		au = append(au, 0, 0, 0, 1)
+		au = append(au, nal...)
+	}
+
+	p := rtp.NewH265Packetizer(100)
+	payloads := p.Packetize(au)
+
+	// One AP with the parameter sets, followed by the IDR fragments
+	assert.Equal(5, len(payloads))
+	assert.Equal(byte(48), (payloads[0][0]>>1)&0x3f)
+
+	for _, payload := range payloads[1:] {
+		assert.True(len(payload) <= 100)
+		assert.Equal(byte(49), (payload[0]>>1)&0x3f)
+	}
+
+	v, s, pp := p.ParameterSets()
+	assert.Equal(vps, v)
+	assert.Equal(sps, s)
+	assert.Equal(pps, pp)
+
+	d := rtp.NewH265Depacketizer()
+
+	for i, payload := range payloads {
+		sample, ok := d.Depacketize(&rtp.Packet{
+			Marker:    i == len(payloads)-1,
+			Sequence:  uint16(i),
+			Timestamp: 9000,
+			Payload:   payload,
+		})
+
+		assert.Equal(i == len(payloads)-1, ok)
+
+		if ok {
+			assert.Equal(au, sample)
+		}
+	}
+}
+
+func TestH265DepacketizerLoss(t *testing.T) {
+	assert := assert.New(t)
+
+	nal := append([]byte{0x26, 0x01}, bytes.Repeat([]byte{0xaa}, 300)...)
+	payloads := rtp.NewH265Packetizer(100).Packetize(nal)
+	d := rtp.NewH265Depacketizer()
+
+	d.Depacketize(&rtp.Packet{Sequence: 0, Payload: payloads[0]})
+	_, ok := d.Depacketize(&rtp.Packet{Sequence: 2, Marker: true, Payload: payloads[2]})
+	assert.False(ok)
+}
+
+func TestH265KeyFrame(t *testing.T) {
+	assert := assert.New(t)
+
+	idr := append([]byte{0x26, 0x01}, bytes.Repeat([]byte{0xaa}, 300)...)
+	trail := append([]byte{0x02, 0x01}, bytes.Repeat([]byte{0xaa}, 300)...)
+
+	assert.True(rtp.H265KeyFrame(idr))
+	assert.True(rtp.H265KeyFrame([]byte{0x40, 0x01, 0x0c}))
+	assert.False(rtp.H265KeyFrame(trail))
+
+	payloads := rtp.NewH265Packetizer(100).Packetize(append([]byte{0, 0, 0, 1}, idr...))
+	assert.True(rtp.H265KeyFrame(payloads[0]))
+	assert.False(rtp.H265KeyFrame(payloads[1]))
+
+	payloads = rtp.NewH265Packetizer(100).Packetize(append([]byte{0, 0, 0, 1}, trail...))
+	assert.False(rtp.H265KeyFrame(payloads[0]))
+}
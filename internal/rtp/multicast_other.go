@@ -0,0 +1,16 @@
+//
+// Description: Multicast time-to-live, where it can't be changed.
+//
+
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package rtp
+
+import "net"
+
+// setMulticastTTL does nothing where the time-to-live can't be changed, so
+// the system default is used.
+func setMulticastTTL(conn *net.UDPConn, ttl int) error {
+	return nil
+}
@@ -0,0 +1,35 @@
+//
+// Description: Multicast time-to-live on Unix systems.
+//
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package rtp
+
+import (
+	"net"
+	"syscall"
+)
+
+// setMulticastTTL sets the time-to-live of the multicast packets sent
+// through a connection.
+func setMulticastTTL(conn *net.UDPConn, ttl int) error {
+	raw, err := conn.SyscallConn()
+
+	if err != nil {
+		return err
+	}
+
+	var serr error
+
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, ttl)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return serr
+}
@@ -0,0 +1,112 @@
+//
+// Description: RTP packets (RFC 3550 section 5.1).
+//
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	rtpVersion = 2
+
+	// HeaderLength is the size of a RTP packet header, without CSRCs and
+	// extensions.
+	HeaderLength = 12
+)
+
+// Packet holds a RTP packet already parsed.
+type Packet struct {
+	Marker      bool
+	PayloadType uint8
+	Sequence    uint16
+	Timestamp   uint32
+	SSRC        uint32
+	CSRC        []uint32
+	Payload     []byte
+}
+
+// Marshal gives the packet in its network format. Extension headers and
+// padding are never used.
+func (p *Packet) Marshal() []byte {
+	b := make([]byte, HeaderLength+4*len(p.CSRC)+len(p.Payload))
+	b[0] = rtpVersion<<6 | byte(len(p.CSRC)&0x0f)
+	b[1] = p.PayloadType & 0x7f
+
+	if p.Marker {
+		b[1] |= 0x80
+	}
+
+	binary.BigEndian.PutUint16(b[2:], p.Sequence)
+	binary.BigEndian.PutUint32(b[4:], p.Timestamp)
+	binary.BigEndian.PutUint32(b[8:], p.SSRC)
+	offset := HeaderLength
+
+	for _, c := range p.CSRC {
+		binary.BigEndian.PutUint32(b[offset:], c)
+		offset += 4
+	}
+
+	copy(b[offset:], p.Payload)
+
+	return b
+}
+
+// Unmarshal parses a RTP packet from its network format. The packet
+// payload points to the same data of b.
+func (p *Packet) Unmarshal(b []byte) error {
+	if len(b) < HeaderLength {
+		return errors.New("RTP packet too short")
+	}
+
+	if b[0]>>6 != rtpVersion {
+		return errors.New("unsupported RTP version")
+	}
+
+	csrcCount := int(b[0] & 0x0f)
+	offset := HeaderLength + 4*csrcCount
+
+	if len(b) < offset {
+		return errors.New("RTP packet too short")
+	}
+
+	p.Marker = b[1]&0x80 != 0
+	p.PayloadType = b[1] & 0x7f
+	p.Sequence = binary.BigEndian.Uint16(b[2:])
+	p.Timestamp = binary.BigEndian.Uint32(b[4:])
+	p.SSRC = binary.BigEndian.Uint32(b[8:])
+	p.CSRC = nil
+
+	for i := 0; i < csrcCount; i++ {
+		p.CSRC = append(p.CSRC, binary.BigEndian.Uint32(b[HeaderLength+4*i:]))
+	}
+
+	// Extension header
+	if b[0]&0x10 != 0 {
+		if len(b) < offset+4 {
+			return errors.New("RTP packet too short")
+		}
+
+		offset += 4 + 4*int(binary.BigEndian.Uint16(b[offset+2:]))
+
+		if len(b) < offset {
+			return errors.New("RTP packet too short")
+		}
+	}
+
+	end := len(b)
+
+	// Padding
+	if b[0]&0x20 != 0 {
+		end -= int(b[end-1])
+
+		if end < offset {
+			return errors.New("invalid RTP padding")
+		}
+	}
+
+	p.Payload = b[offset:end]
+
+	return nil
+}
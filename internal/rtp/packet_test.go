@@ -0,0 +1,33 @@
+//
+// Description: RTP packet tests.
+//
+package rtp_test
+
+import (
+	"testing"
+
+	"github.com/rsfreitas/go-rtsp/internal/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPacket(t *testing.T) {
+	assert := assert.New(t)
+
+	p := &rtp.Packet{
+		Marker:      true,
+		PayloadType: 96,
+		Sequence:    65535,
+		Timestamp:   3000,
+		SSRC:        0x46a81ad7,
+		Payload:     []byte{1, 2, 3, 4},
+	}
+
+	b := p.Marshal()
+	assert.Equal([]byte{0x80, 0xe0, 0xff, 0xff, 0, 0, 0x0b, 0xb8, 0x46, 0xa8, 0x1a, 0xd7, 1, 2, 3, 4}, b)
+
+	var q rtp.Packet
+	assert.Nil(q.Unmarshal(b))
+	assert.Equal(*p, q)
+
+	assert.NotNil(q.Unmarshal(b[:8]))
+}
@@ -0,0 +1,83 @@
+//
+// Description: Media samples packetization.
+//
+package rtp
+
+import "strings"
+
+// Packetizer splits a media sample, such as a video frame, into RTP
+// payloads. The last payload of a sample is always sent with the marker
+// bit set.
+type Packetizer interface {
+	Packetize(sample []byte) [][]byte
+}
+
+// rawPacketizer sends each sample as a single RTP payload.
+type rawPacketizer struct{}
+
+func (r *rawPacketizer) Packetize(sample []byte) [][]byte {
+	return [][]byte{sample}
+}
+
+// NewRawPacketizer creates a Packetizer that doesn't split samples, i.e,
+// they must already fit inside a RTP packet.
+func NewRawPacketizer() Packetizer {
+	return &rawPacketizer{}
+}
+
+// Depacketizer reassembles media samples, such as video frames, from RTP
+// packets received in sequence.
+type Depacketizer interface {
+	// Depacketize receives a RTP packet and gives a complete sample when
+	// the packet finishes it.
+	Depacketize(p *Packet) ([]byte, bool)
+}
+
+// AudioPacketizer is a Packetizer which splits audio samples into frames.
+// Since each frame has its own media time, the payloads of a sample are
+// sent with different timestamps.
+type AudioPacketizer interface {
+	Packetizer
+
+	// Duration gives how long a payload lasts, in RTP clock rate units.
+	Duration(payload []byte) uint32
+}
+
+// rawDepacketizer gives each RTP payload as a sample.
+type rawDepacketizer struct{}
+
+func (r *rawDepacketizer) Depacketize(p *Packet) ([]byte, bool) {
+	return p.Payload, len(p.Payload) > 0
+}
+
+// NewRawDepacketizer creates a Depacketizer for payload formats that carry
+// a whole sample inside each RTP packet, such as G.711, G.722 and Opus.
+func NewRawDepacketizer() Depacketizer {
+	return &rawDepacketizer{}
+}
+
+// FrameDepacketizer is a Depacketizer for payload formats that carry
+// several audio frames inside a RTP packet, such as AAC, which must be
+// handled one by one.
+type FrameDepacketizer interface {
+	Depacketizer
+
+	// DepacketizeFrames receives a RTP packet and gives the complete frames
+	// found inside it.
+	DepacketizeFrames(p *Packet) [][]byte
+}
+
+// formatParameter gives the value of a parameter from SDP fmtp parameters,
+// such as "packetization-mode=1; profile-level-id=42C01F", or an empty
+// string if it doesn't exist.
+func formatParameter(fmtp, name string) string {
+	for _, p := range strings.Split(fmtp, ";") {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+
+		if len(kv) == 2 && strings.EqualFold(kv[0], name) {
+			return strings.TrimSpace(kv[1])
+		}
+	}
+
+	return ""
+}
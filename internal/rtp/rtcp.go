@@ -0,0 +1,285 @@
+//
+// Description: RTCP packets (RFC 3550 section 6).
+//
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+const (
+	rtcpSenderReport      = 200
+	rtcpReceiverReport    = 201
+	rtcpSourceDescription = 202
+	rtcpBye               = 203
+
+	rtcpHeaderLength      = 4
+	receptionReportLength = 24
+
+	sdesCNAME = 1
+)
+
+// ntpEpochOffset is the number of seconds between the NTP epoch (1900) and
+// the Unix epoch (1970).
+const ntpEpochOffset = 2208988800
+
+// NTPTime converts a time to the 64 bits NTP timestamp format.
+func NTPTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+
+	return seconds<<32 | fraction
+}
+
+// ReceptionReport holds the reception quality of a RTP source, as seen by
+// the report sender.
+type ReceptionReport struct {
+	SSRC             uint32
+	FractionLost     uint8
+	TotalLost        int32
+	HighestSequence  uint32
+	Jitter           uint32
+	LastSR           uint32
+	DelaySinceLastSR uint32
+}
+
+func (r *ReceptionReport) marshal(b []byte) {
+	binary.BigEndian.PutUint32(b, r.SSRC)
+	binary.BigEndian.PutUint32(b[4:], uint32(r.TotalLost)&0xffffff)
+	b[4] = r.FractionLost
+	binary.BigEndian.PutUint32(b[8:], r.HighestSequence)
+	binary.BigEndian.PutUint32(b[12:], r.Jitter)
+	binary.BigEndian.PutUint32(b[16:], r.LastSR)
+	binary.BigEndian.PutUint32(b[20:], r.DelaySinceLastSR)
+}
+
+func (r *ReceptionReport) unmarshal(b []byte) {
+	r.SSRC = binary.BigEndian.Uint32(b)
+	r.FractionLost = b[4]
+
+	// The cumulative number of packets lost is a 24 bits signed value
+	lost := binary.BigEndian.Uint32(b[4:]) & 0xffffff
+
+	if lost&0x800000 != 0 {
+		lost |= 0xff000000
+	}
+
+	r.TotalLost = int32(lost)
+	r.HighestSequence = binary.BigEndian.Uint32(b[8:])
+	r.Jitter = binary.BigEndian.Uint32(b[12:])
+	r.LastSR = binary.BigEndian.Uint32(b[16:])
+	r.DelaySinceLastSR = binary.BigEndian.Uint32(b[20:])
+}
+
+// RTCPPacket is a single RTCP packet of a compound packet.
+type RTCPPacket interface {
+	Marshal() []byte
+}
+
+// SenderReport is a RTCP SR packet.
+type SenderReport struct {
+	SSRC        uint32
+	NTPTime     uint64
+	RTPTime     uint32
+	PacketCount uint32
+	OctetCount  uint32
+	Reports     []ReceptionReport
+}
+
+// Marshal gives the packet in its network format.
+func (s *SenderReport) Marshal() []byte {
+	b := rtcpHeader(rtcpSenderReport, len(s.Reports), 24+receptionReportLength*len(s.Reports))
+	binary.BigEndian.PutUint32(b[4:], s.SSRC)
+	binary.BigEndian.PutUint64(b[8:], s.NTPTime)
+	binary.BigEndian.PutUint32(b[16:], s.RTPTime)
+	binary.BigEndian.PutUint32(b[20:], s.PacketCount)
+	binary.BigEndian.PutUint32(b[24:], s.OctetCount)
+
+	for i := range s.Reports {
+		s.Reports[i].marshal(b[28+receptionReportLength*i:])
+	}
+
+	return b
+}
+
+// ReceiverReport is a RTCP RR packet.
+type ReceiverReport struct {
+	SSRC    uint32
+	Reports []ReceptionReport
+}
+
+// Marshal gives the packet in its network format.
+func (r *ReceiverReport) Marshal() []byte {
+	b := rtcpHeader(rtcpReceiverReport, len(r.Reports), 4+receptionReportLength*len(r.Reports))
+	binary.BigEndian.PutUint32(b[4:], r.SSRC)
+
+	for i := range r.Reports {
+		r.Reports[i].marshal(b[8+receptionReportLength*i:])
+	}
+
+	return b
+}
+
+// SourceDescription is a RTCP SDES packet with the CNAME of a single
+// source.
+type SourceDescription struct {
+	SSRC  uint32
+	CNAME string
+}
+
+// Marshal gives the packet in its network format.
+func (s *SourceDescription) Marshal() []byte {
+	// SSRC, CNAME item and at least one null octet ending the item list,
+	// padded to 32 bits.
+	size := 4 + 2 + len(s.CNAME) + 1
+	size = (size + 3) &^ 3
+
+	b := rtcpHeader(rtcpSourceDescription, 1, size)
+	binary.BigEndian.PutUint32(b[4:], s.SSRC)
+	b[8] = sdesCNAME
+	b[9] = byte(len(s.CNAME))
+	copy(b[10:], s.CNAME)
+
+	return b
+}
+
+// Bye is a RTCP BYE packet.
+type Bye struct {
+	SSRCs  []uint32
+	Reason string
+}
+
+// Marshal gives the packet in its network format.
+func (y *Bye) Marshal() []byte {
+	size := 4 * len(y.SSRCs)
+
+	if y.Reason != "" {
+		size += (1 + len(y.Reason) + 3) &^ 3
+	}
+
+	b := rtcpHeader(rtcpBye, len(y.SSRCs), size)
+
+	for i, ssrc := range y.SSRCs {
+		binary.BigEndian.PutUint32(b[4+4*i:], ssrc)
+	}
+
+	if y.Reason != "" {
+		offset := 4 + 4*len(y.SSRCs)
+		b[offset] = byte(len(y.Reason))
+		copy(b[offset+1:], y.Reason)
+	}
+
+	return b
+}
+
+// rtcpHeader creates a RTCP packet with its header filled, where size is
+// the packet length without the header.
+func rtcpHeader(packetType, count, size int) []byte {
+	b := make([]byte, rtcpHeaderLength+size)
+	b[0] = rtpVersion<<6 | byte(count&0x1f)
+	b[1] = byte(packetType)
+	binary.BigEndian.PutUint16(b[2:], uint16((rtcpHeaderLength+size)/4-1))
+
+	return b
+}
+
+// MarshalRTCP gives a compound RTCP packet in its network format.
+func MarshalRTCP(packets ...RTCPPacket) []byte {
+	var b []byte
+
+	for _, p := range packets {
+		b = append(b, p.Marshal()...)
+	}
+
+	return b
+}
+
+// UnmarshalRTCP parses a compound RTCP packet. Packet types not handled
+// here are skipped.
+func UnmarshalRTCP(b []byte) ([]RTCPPacket, error) {
+	var packets []RTCPPacket
+
+	for len(b) > 0 {
+		if len(b) < rtcpHeaderLength {
+			return nil, errors.New("RTCP packet too short")
+		}
+
+		if b[0]>>6 != rtpVersion {
+			return nil, errors.New("unsupported RTCP version")
+		}
+
+		count := int(b[0] & 0x1f)
+		size := 4 * (int(binary.BigEndian.Uint16(b[2:])) + 1)
+
+		if size > len(b) {
+			return nil, errors.New("RTCP packet too short")
+		}
+
+		body := b[rtcpHeaderLength:size]
+
+		switch b[1] {
+		case rtcpSenderReport:
+			if len(body) < 24+receptionReportLength*count {
+				return nil, errors.New("invalid RTCP sender report")
+			}
+
+			sr := &SenderReport{
+				SSRC:        binary.BigEndian.Uint32(body),
+				NTPTime:     binary.BigEndian.Uint64(body[4:]),
+				RTPTime:     binary.BigEndian.Uint32(body[12:]),
+				PacketCount: binary.BigEndian.Uint32(body[16:]),
+				OctetCount:  binary.BigEndian.Uint32(body[20:]),
+				Reports:     unmarshalReports(body[24:], count),
+			}
+
+			packets = append(packets, sr)
+
+		case rtcpReceiverReport:
+			if len(body) < 4+receptionReportLength*count {
+				return nil, errors.New("invalid RTCP receiver report")
+			}
+
+			packets = append(packets, &ReceiverReport{
+				SSRC:    binary.BigEndian.Uint32(body),
+				Reports: unmarshalReports(body[4:], count),
+			})
+
+		case rtcpBye:
+			if len(body) < 4*count {
+				return nil, errors.New("invalid RTCP BYE")
+			}
+
+			bye := &Bye{}
+
+			for i := 0; i < count; i++ {
+				bye.SSRCs = append(bye.SSRCs, binary.BigEndian.Uint32(body[4*i:]))
+			}
+
+			if reason := body[4*count:]; len(reason) > 0 && int(reason[0]) < len(reason) {
+				bye.Reason = string(reason[1 : 1+reason[0]])
+			}
+
+			packets = append(packets, bye)
+		}
+
+		b = b[size:]
+	}
+
+	return packets, nil
+}
+
+func unmarshalReports(b []byte, count int) []ReceptionReport {
+	if count == 0 {
+		return nil
+	}
+
+	reports := make([]ReceptionReport, count)
+
+	for i := range reports {
+		reports[i].unmarshal(b[receptionReportLength*i:])
+	}
+
+	return reports
+}
@@ -0,0 +1,59 @@
+//
+// Description: RTCP packet tests.
+//
+package rtp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rsfreitas/go-rtsp/internal/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRTCPCompoundPacket(t *testing.T) {
+	assert := assert.New(t)
+
+	sr := &rtp.SenderReport{
+		SSRC:        0x11223344,
+		NTPTime:     rtp.NTPTime(time.Unix(1557000000, 500000000)),
+		RTPTime:     90000,
+		PacketCount: 10,
+		OctetCount:  12000,
+	}
+
+	rr := &rtp.ReceiverReport{
+		SSRC: 0x55667788,
+		Reports: []rtp.ReceptionReport{
+			{
+				SSRC:             0x11223344,
+				FractionLost:     64,
+				TotalLost:        -2,
+				HighestSequence:  1000,
+				Jitter:           90,
+				LastSR:           0xaabbccdd,
+				DelaySinceLastSR: 65536,
+			},
+		},
+	}
+
+	b := rtp.MarshalRTCP(sr, &rtp.SourceDescription{SSRC: sr.SSRC, CNAME: "cam"},
+		rr, &rtp.Bye{SSRCs: []uint32{sr.SSRC}, Reason: "teardown"})
+
+	// SR, SDES, RR and BYE, all of them aligned to 32 bits
+	assert.Equal(0, len(b)%4)
+	assert.Equal(byte(200), b[1])
+
+	packets, err := rtp.UnmarshalRTCP(b)
+	assert.Nil(err)
+
+	// SDES is skipped
+	assert.Equal(3, len(packets))
+	assert.Equal(sr, packets[0])
+	assert.Equal(uint64(1557000000+2208988800)<<32|1<<31, sr.NTPTime)
+	assert.Equal(rr, packets[1])
+	assert.Equal(&rtp.Bye{SSRCs: []uint32{sr.SSRC}, Reason: "teardown"}, packets[2])
+
+	_, err = rtp.UnmarshalRTCP(b[:len(b)-1])
+	assert.NotNil(err)
+}
@@ -6,86 +6,812 @@
 package rtp
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"fmt"
 	"net"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/wernerd/gortp/src/net/rtp"
+	"github.com/rsfreitas/go-rtsp/internal/srtp"
 )
 
 // Setup holds options to initialize a RTP session between server and client.
 type Setup struct {
+	// ServerAddr and ClientAddr are IPv4 or IPv6 addresses, which may
+	// have a zone (fe80::1%eth0). The session is bound to ServerAddr, or
+	// to all local addresses if it is empty.
 	ServerPort  int
 	ServerAddr  string
 	ClientPorts []int
 	ClientAddr  string
+
+	// TTL is the time-to-live of the packets sent when ClientAddr is a
+	// multicast group. If not set, the system default is used.
+	TTL int
+
+	// Interleaved holds the RTP and RTCP channels when the session data is
+	// transferred using the RTSP connection, instead of UDP. In this case,
+	// Writer must be the connection used to send interleaved frames.
+	Interleaved []int
+	Writer      InterleavedWriter
+
+	// PayloadType is the RTP payload type of the data sent to the client.
+	PayloadType uint8
+
+	// ClockRate is the RTP clock rate of the data sent to the client, used
+	// to map its timestamps to the wall clock inside sender reports.
+	ClockRate int
+
+	// CNAME identifies the source of the data inside RTCP packets. Sessions
+	// whose data must be synchronized, such as audio and video from the
+	// same stream, must use the same CNAME.
+	CNAME string
+
+	// Activity, if set, is called whenever a RTCP packet is received from
+	// the client, telling that it is still alive.
+	Activity func()
+
+	// Receive, if set, is called with every RTP packet sent by the client,
+	// such as the media of a RECORD session. Otherwise they're discarded.
+	Receive func(p *Packet)
+
+	// SRTPKey, if set, is the SRTP master key, followed by its master salt,
+	// protecting the session packets in both directions (RFC 3711).
+	// Packets received without a valid authentication tag are discarded.
+	SRTPKey []byte
+}
+
+const (
+	// outgoingQueueSize is the number of packets a session holds before
+	// discarding new ones, when its client can't keep up with the stream.
+	outgoingQueueSize = 512
+
+	// reportInterval is the interval between RTCP sender reports.
+	reportInterval = 5 * time.Second
+
+	maxPacketSize = 65536
+)
+
+// InterleavedWriter is the required interface to send interleaved frames
+// through a RTSP connection.
+type InterleavedWriter interface {
+	WriteInterleaved(channel int, payload []byte) error
+}
+
+// Statistics holds the counters of a RTP session and the reception quality
+// reported by its client, through RTCP receiver reports.
+type Statistics struct {
+	PacketsSent uint64
+	OctetsSent  uint64
+
+	// PacketsLost is the cumulative number of packets lost and FractionLost
+	// is the fraction lost since the previous report, from 0 to 1.
+	PacketsLost  int
+	FractionLost float64
+
+	Jitter time.Duration
+
+	// RTT is the round-trip time between server and client. It is only
+	// known after the client reports a sender report reception.
+	RTT time.Duration
+
+	// LastReport is the time of the last receiver report. It is zero if the
+	// client has never sent one.
+	LastReport time.Time
 }
 
 // Session holds a RTP session, to transfer data to the client.
 type Session struct {
-	s            *rtp.Session
-	stopReceiver chan bool
-	stopSender   chan bool
-	port         int
+	rtpConn     *net.UDPConn
+	rtcpConn    *net.UDPConn
+	rtpAddr     *net.UDPAddr
+	rtcpAddr    *net.UDPAddr
+	port        int
+	interleaved []int
+	writer      InterleavedWriter
+	closeOnce   sync.Once
+	stop        chan struct{}
+	senderDone  chan struct{}
+	outgoing    chan *Packet
+	clockRate   int
+	cname       string
+	activity    func()
+	receive     func(p *Packet)
+
+	// SRTP contexts of the packets sent and received, when the session
+	// is protected.
+	protect   *srtp.Context
+	unprotect *srtp.Context
+
+	lock            sync.Mutex
+	playing         bool
+	talkspurt       bool
+	ssrc            uint32
+	sequence        uint16
+	timestampOffset uint32
+	payloadType     uint8
+
+	// Relayed packets: synced tells if a sync point was already forwarded
+	// since the session started playing and sequenceOffset maps the source
+	// sequence numbers to the session ones.
+	synced         bool
+	sequenceOffset uint16
+
+	// Trick-play: media timestamps after scaleBase are sent at 1/scale of
+	// their pace, starting from scaleOrigin, so clients present them in
+	// real time.
+	scale       float64
+	scaleBase   uint32
+	scaleOrigin uint32
+
+	// Counters and sender report state
+	stats         Statistics
+	lastTimestamp uint32
+	lastSent      time.Time
+	lastSR        uint32
 }
 
+// Close finishes the session, sending a RTCP BYE to the client.
 func (r *Session) Close() {
-	// closes goroutines (send/recv)
-	r.stopReceiver <- true
-	fmt.Println("Closing RTP session")
+	r.closeOnce.Do(func() {
+		// closes goroutines (send/recv)
+		close(r.stop)
+		<-r.senderDone
+
+		r.writeRTCP(MarshalRTCP(r.report(), r.sourceDescription(), &Bye{
+			SSRCs: []uint32{r.ssrc},
+		}))
+
+		if r.rtpConn != nil {
+			r.rtpConn.Close()
+			r.rtcpConn.Close()
+		}
+	})
+}
+
+// Play enables the session to send data to the client.
+func (r *Session) Play() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.playing {
+		r.talkspurt = true
+		r.synced = false
+	}
+
+	r.playing = true
 }
 
+// Pause stops sending data to the client.
 func (r *Session) Pause() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.playing = false
+}
+
+// IsPlaying tells if the session is currently sending data to the client.
+func (r *Session) IsPlaying() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.playing
+}
+
+// SSRC gives the synchronization source identifier of the data sent to the
+// client.
+func (r *Session) SSRC() uint32 {
+	return r.ssrc
+}
+
+// Next gives the sequence number of the next packet sent to the client and
+// the session timestamp of a media rtpTime, as used by the RTSP RTP-Info
+// header.
+func (r *Session) Next(rtpTime uint32) (uint16, uint32) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.sequence, r.timestamp(rtpTime)
+}
+
+// SetScale changes the playback rate (RTSP Scale header) from the media
+// rtpTime on. Timestamps remain continuous across changes, since the new
+// rate starts where the previous one would have placed rtpTime. Negative
+// values play backwards.
+func (r *Session) SetScale(scale float64, rtpTime uint32) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if scale == 0 {
+		scale = 1
+	}
+
+	r.scaleOrigin = r.timestamp(rtpTime) - r.timestampOffset
+	r.scaleBase = rtpTime
+	r.scale = scale
+}
+
+// timestamp translates a media rtpTime to the session timestamp space. It
+// must be called with the session lock held.
+func (r *Session) timestamp(rtpTime uint32) uint32 {
+	if r.scale == 1 {
+		return r.timestampOffset + r.scaleOrigin + (rtpTime - r.scaleBase)
+	}
+
+	elapsed := float64(int32(rtpTime - r.scaleBase))
+
+	return r.timestampOffset + r.scaleOrigin + uint32(int32(elapsed/r.scale))
+}
+
+// Statistics gives the session counters and the last reception quality
+// reported by the client.
+func (r *Session) Statistics() Statistics {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.stats
+}
+
+// WritePayloads sends the RTP payloads of a media sample to the client,
+// if the session is playing. The rtpTime is the sample timestamp, in the
+// media clock rate, which is translated to the session timestamp space.
+// Payloads are queued and sent asynchronously, so a slow client does not
+// block the caller.
+func (r *Session) WritePayloads(rtpTime uint32, payloads [][]byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.playing {
+		return
+	}
+
+	for i, payload := range payloads {
+		r.queue(i == len(payloads)-1, rtpTime, payload)
+	}
+}
+
+// WriteAudioPayloads sends the RTP payloads of an audio sample to the
+// client, if the session is playing. Each payload is a frame lasting its
+// durations entry, so its timestamp comes after the previous one. Since
+// audio is continuous, only the first packet after the session starts
+// playing has the marker bit set (RFC 3551 section 4.1).
+func (r *Session) WriteAudioPayloads(rtpTime uint32, payloads [][]byte, durations []uint32) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.playing {
+		return
+	}
+
+	for i, payload := range payloads {
+		r.queue(r.talkspurt, rtpTime, payload)
+		r.talkspurt = false
+		rtpTime += durations[i]
+	}
+}
+
+// Forward relays a RTP packet received from another source, such as a
+// client publishing a stream, if the session is playing. The payload is
+// sent as is, with the session SSRC, and rtpTime is the packet timestamp
+// relative to the source beginning. After the session starts playing,
+// packets are discarded until a sync point, such as the beginning of a
+// video key frame, so the client can decode everything it receives. Gaps
+// in the source sequence numbers are kept, so the client detects losses.
+func (r *Session) Forward(p *Packet, rtpTime uint32, sync bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.playing {
+		return
+	}
+
+	if !r.synced {
+		if !sync {
+			return
+		}
+
+		r.synced = true
+		r.sequenceOffset = r.sequence - p.Sequence
+	}
+
+	r.sequence = p.Sequence + r.sequenceOffset
+	r.queue(p.Marker, rtpTime, p.Payload)
+}
+
+// Resync makes the session discard forwarded packets until the next sync
+// point, as when it starts playing. It is used when the source of the
+// packets restarts, so the client receives continuous sequence numbers.
+func (r *Session) Resync() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.synced = false
+}
+
+// queue puts a packet to be sent to the client. It must be called with the
+// session lock held.
+func (r *Session) queue(marker bool, rtpTime uint32, payload []byte) {
+	p := &Packet{
+		Marker:      marker,
+		PayloadType: r.payloadType,
+		Sequence:    r.sequence,
+		Timestamp:   r.timestamp(rtpTime),
+		SSRC:        r.ssrc,
+		Payload:     payload,
+	}
+
+	r.sequence++
+
+	select {
+	case r.outgoing <- p:
+	default:
+		// The client can't keep up, so the packet is lost
+	}
+}
+
+// send transfers a packet to the client using the session transport.
+func (r *Session) send(p *Packet) error {
+	b := p.Marshal()
+
+	if r.protect != nil {
+		var err error
+
+		if b, err = r.protect.EncryptRTP(b); err != nil {
+			return err
+		}
+	}
+
+	if r.IsInterleaved() {
+		return r.writer.WriteInterleaved(r.interleaved[0], b)
+	}
+
+	_, err := r.rtpConn.WriteToUDP(b, r.rtpAddr)
+
+	return err
+}
+
+// sent updates the session counters after a packet was sent. It tells if
+// this was the first packet of the session.
+func (r *Session) sent(p *Packet) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.stats.PacketsSent++
+	r.stats.OctetsSent += uint64(len(p.Payload))
+	r.lastTimestamp = p.Timestamp
+	r.lastSent = time.Now()
+
+	return r.stats.PacketsSent == 1
+}
+
+// writeRTCP transfers a RTCP packet to the client using the session
+// transport.
+func (r *Session) writeRTCP(b []byte) error {
+	if r.protect != nil {
+		var err error
+
+		if b, err = r.protect.EncryptRTCP(b); err != nil {
+			return err
+		}
+	}
+
+	if r.IsInterleaved() {
+		if len(r.interleaved) < 2 {
+			return nil
+		}
+
+		return r.writer.WriteInterleaved(r.interleaved[1], b)
+	}
+
+	_, err := r.rtcpConn.WriteToUDP(b, r.rtcpAddr)
+
+	return err
+}
+
+// report gives the RTCP report of the session. It is a sender report, which
+// maps the RTP timestamps to the wall clock, if data was already sent, or
+// an empty receiver report otherwise.
+func (r *Session) report() RTCPPacket {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.stats.PacketsSent == 0 {
+		return &ReceiverReport{
+			SSRC: r.ssrc,
+		}
+	}
+
+	now := time.Now()
+	ntp := NTPTime(now)
+	elapsed := now.Sub(r.lastSent)
+
+	// Keeps the middle 32 bits of the NTP time, so the client round-trip
+	// time can be computed when it reports this sender report.
+	r.lastSR = uint32(ntp >> 16)
+
+	return &SenderReport{
+		SSRC:        r.ssrc,
+		NTPTime:     ntp,
+		RTPTime:     r.lastTimestamp + uint32(int64(elapsed)*int64(r.clockRate)/int64(time.Second)),
+		PacketCount: uint32(r.stats.PacketsSent),
+		OctetCount:  uint32(r.stats.OctetsSent),
+	}
+}
+
+func (r *Session) sourceDescription() RTCPPacket {
+	return &SourceDescription{
+		SSRC:  r.ssrc,
+		CNAME: r.cname,
+	}
+}
+
+// sendReport sends a sender report to the client, if data was already sent.
+func (r *Session) sendReport() {
+	report := r.report()
+
+	if _, ok := report.(*SenderReport); !ok {
+		return
+	}
+
+	r.writeRTCP(MarshalRTCP(report, r.sourceDescription()))
+}
+
+// handleRTCP processes a compound RTCP packet sent by the client, keeping
+// the reception quality reported for our data.
+func (r *Session) handleRTCP(b []byte) {
+	if r.unprotect != nil {
+		var err error
+
+		if b, err = r.unprotect.DecryptRTCP(b); err != nil {
+			return
+		}
+	}
+
+	packets, err := UnmarshalRTCP(b)
+
+	if err != nil {
+		return
+	}
+
+	if r.activity != nil {
+		r.activity()
+	}
+
+	for _, p := range packets {
+		var reports []ReceptionReport
+
+		switch p := p.(type) {
+		case *ReceiverReport:
+			reports = p.Reports
+
+		case *SenderReport:
+			reports = p.Reports
+		}
+
+		for _, report := range reports {
+			if report.SSRC == r.ssrc {
+				r.updateStatistics(&report)
+			}
+		}
+	}
+}
+
+func (r *Session) updateStatistics(report *ReceptionReport) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	r.stats.LastReport = now
+	r.stats.PacketsLost = int(report.TotalLost)
+	r.stats.FractionLost = float64(report.FractionLost) / 256
+
+	if r.clockRate > 0 {
+		r.stats.Jitter = time.Duration(int64(report.Jitter) * int64(time.Second) / int64(r.clockRate))
+	}
+
+	// RTT = now - LSR - DLSR (RFC 3550 section 6.4.1), in 1/65536 seconds
+	if report.LastSR != 0 && report.LastSR == r.lastSR {
+		elapsed := uint32(NTPTime(now)>>16) - report.LastSR
+
+		if elapsed >= report.DelaySinceLastSR {
+			rtt := elapsed - report.DelaySinceLastSR
+			r.stats.RTT = time.Duration(int64(rtt) * int64(time.Second) / 65536)
+		}
+	}
 }
 
 func (r *Session) Port() int {
 	return r.port
 }
 
+// Interleaved gives the RTP and RTCP channels used by the session when
+// its data is being transferred through the RTSP connection. It returns
+// nil for UDP sessions.
+func (r *Session) Interleaved() []int {
+	return r.interleaved
+}
+
+// IsInterleaved tells if the session transfers its data through the RTSP
+// connection.
+func (r *Session) IsInterleaved() bool {
+	return r.interleaved != nil
+}
+
+// HandleInterleaved receives the payload of an interleaved frame sent by the
+// client using one of the session channels.
+func (r *Session) HandleInterleaved(channel int, payload []byte) {
+	if len(r.interleaved) > 1 && channel == r.interleaved[1] {
+		r.handleRTCP(payload)
+	} else {
+		r.handleRTP(payload)
+	}
+}
+
+// handleRTP delivers a RTP packet sent by the client to the session
+// receiver, if any.
+func (r *Session) handleRTP(b []byte) {
+	if r.receive == nil {
+		return
+	}
+
+	if r.unprotect != nil {
+		var err error
+
+		if b, err = r.unprotect.DecryptRTP(b); err != nil {
+			return
+		}
+	}
+
+	var p Packet
+
+	if err := p.Unmarshal(b); err != nil {
+		return
+	}
+
+	if r.activity != nil {
+		r.activity()
+	}
+
+	r.receive(&p)
+}
+
+// rtpReceiver handles RTP data sent by the client until the connection is
+// closed.
 func rtpReceiver(r *Session) {
-	dataReceiver := r.s.CreateDataReceiveChan()
+	b := make([]byte, maxPacketSize)
 
-loop:
 	for {
-		select {
-		case rp := <-dataReceiver:
-			rp.FreePacket()
+		n, addr, err := r.rtpConn.ReadFromUDP(b)
 
-		case <-r.stopReceiver:
-			break loop
+		if err != nil {
+			break
 		}
+
+		if !fromPeer(addr, r.rtpAddr) {
+			continue
+		}
+
+		// Depacketizers may keep the payload until the sample is
+		// complete, so it can't share the receiving buffer.
+		r.handleRTP(append([]byte(nil), b[:n]...))
 	}
+}
+
+// rtcpReceiver handles RTCP packets sent by the client until the
+// connection is closed.
+func rtcpReceiver(r *Session) {
+	b := make([]byte, maxPacketSize)
+
+	for {
+		n, addr, err := r.rtcpConn.ReadFromUDP(b)
+
+		if err != nil {
+			break
+		}
+
+		if !fromPeer(addr, r.rtcpAddr) {
+			continue
+		}
 
-	fmt.Println("Closing receiver")
+		r.handleRTCP(b[:n])
+	}
+}
+
+// fromPeer tells if a packet received from addr was sent by the other side
+// of the session, whose address is peer, so nobody else can inject data or
+// keep the session alive. A peer without port only has its IP checked.
+func fromPeer(addr, peer *net.UDPAddr) bool {
+	return addr.IP.Equal(peer.IP) && (peer.Port == 0 || addr.Port == peer.Port)
 }
 
+// rtpSender sends the queued packets and the periodic sender reports to
+// the client. The first report is sent right after the first packet, so
+// the client can synchronize the streams as soon as possible.
 func rtpSender(r *Session) {
+	ticker := time.NewTicker(reportInterval)
+
+	defer func() {
+		ticker.Stop()
+		close(r.senderDone)
+	}()
+
+	for {
+		select {
+		case p := <-r.outgoing:
+			if err := r.send(p); err == nil && r.sent(p) {
+				r.sendReport()
+			}
+
+		case <-ticker.C:
+			r.sendReport()
+
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// random32 gives a random value to be used as SSRC, initial sequence
+// number or timestamp offset.
+func random32() uint32 {
+	var b [4]byte
+	rand.Read(b[:])
+
+	return binary.BigEndian.Uint32(b[:])
 }
 
-func NewSession(options Setup) *Session {
-	serverAddr, _ := net.ResolveIPAddr("ip", options.ServerAddr)
-	clientAddr, _ := net.ResolveIPAddr("ip", options.ClientAddr)
+// ParseAddr parses an IP address, giving its IPv6 zone apart.
+func ParseAddr(s string) (net.IP, string) {
+	var zone string
+
+	if i := strings.LastIndex(s, "%"); i >= 0 {
+		s, zone = s[:i], s[i+1:]
+	}
+
+	return net.ParseIP(s), zone
+}
+
+func newSession(options Setup) (*Session, error) {
+	r := &Session{
+		stop:            make(chan struct{}),
+		senderDone:      make(chan struct{}),
+		outgoing:        make(chan *Packet, outgoingQueueSize),
+		clockRate:       options.ClockRate,
+		cname:           options.CNAME,
+		activity:        options.Activity,
+		receive:         options.Receive,
+		ssrc:            random32(),
+		sequence:        uint16(random32()),
+		timestampOffset: random32(),
+		scale:           1,
+		payloadType:     options.PayloadType,
+	}
+
+	if r.cname == "" {
+		r.cname = fmt.Sprintf("%08x", r.ssrc)
+	}
+
+	// Each direction keeps its own packet indexes
+	if options.SRTPKey != nil {
+		var err error
+
+		if r.protect, err = srtp.NewContext(options.SRTPKey); err != nil {
+			return nil, err
+		}
+
+		if r.unprotect, err = srtp.NewContext(options.SRTPKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+func newInterleavedSession(options Setup) (*Session, error) {
+	// RTCP uses the channel after the RTP one, if it isn't informed. The
+	// last channel has none, so RTCP isn't transferred.
+	channels := []int{options.Interleaved[0]}
+
+	if len(options.Interleaved) > 1 {
+		channels = append(channels, options.Interleaved[1])
+	} else if channels[0] < 255 {
+		channels = append(channels, channels[0]+1)
+	}
+
+	r, err := newSession(options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.interleaved = channels
+	r.writer = options.Writer
+
+	go rtpSender(r)
+
+	return r, nil
+}
+
+// NewSession creates a new RTP session. UDP sessions use the ServerPort
+// for RTP and the next one for RTCP. When ClientAddr is a multicast group,
+// the session data is sent once to all of its members and nothing is
+// received from them.
+func NewSession(options Setup) (*Session, error) {
+	if options.Interleaved != nil {
+		return newInterleavedSession(options)
+	}
+
+	r, err := newSession(options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	serverIP, serverZone := ParseAddr(options.ServerAddr)
+	clientIP, clientZone := ParseAddr(options.ClientAddr)
+
+	rtpConn, err := net.ListenUDP("udp", &net.UDPAddr{
+		IP:   serverIP,
+		Port: options.ServerPort,
+		Zone: serverZone,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	rtcpConn, err := net.ListenUDP("udp", &net.UDPAddr{
+		IP:   serverIP,
+		Port: options.ServerPort + 1,
+		Zone: serverZone,
+	})
+
+	if err != nil {
+		rtpConn.Close()
+		return nil, err
+	}
+
+	if clientIP.IsMulticast() && options.TTL > 0 {
+		for _, c := range []*net.UDPConn{rtpConn, rtcpConn} {
+			if err := setMulticastTTL(c, options.TTL); err != nil {
+				rtpConn.Close()
+				rtcpConn.Close()
+				return nil, err
+			}
+		}
+	}
 
-	tpLocal, _ := rtp.NewTransportUDP(serverAddr, options.ServerPort, "")
-	session := rtp.NewSession(tpLocal, tpLocal)
 	portB := options.ClientPorts[0] + 1
 
 	if len(options.ClientPorts) > 1 {
 		portB = options.ClientPorts[1]
 	}
 
-	session.AddRemote(&rtp.Address{clientAddr.IP, options.ClientPorts[0], portB, ""})
+	r.port = options.ServerPort
+	r.rtpConn = rtpConn
+	r.rtcpConn = rtcpConn
+	r.rtpAddr = &net.UDPAddr{
+		IP:   clientIP,
+		Port: options.ClientPorts[0],
+		Zone: clientZone,
+	}
 
-	r := &Session{
-		port:         options.ServerPort,
-		s:            session,
-		stopReceiver: make(chan bool),
-		stopSender:   make(chan bool),
+	r.rtcpAddr = &net.UDPAddr{
+		IP:   clientIP,
+		Port: portB,
+		Zone: clientZone,
+	}
+
+	// The server doesn't join multicast groups, so nothing is received
+	// from their members, which are only known through RTSP.
+	if !clientIP.IsMulticast() {
+		go rtpReceiver(r)
+		go rtcpReceiver(r)
 	}
 
-	go rtpReceiver(r)
-	session.ListenOnTransports()
 	go rtpSender(r)
 
-	fmt.Println("New RTP session")
-	return r
+	return r, nil
 }
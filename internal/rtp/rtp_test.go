@@ -0,0 +1,293 @@
+//
+// Description: RTP session tests.
+//
+package rtp_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rsfreitas/go-rtsp/internal/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+type discardWriter struct{}
+
+func (discardWriter) WriteInterleaved(channel int, payload []byte) error {
+	return nil
+}
+
+func TestSessionScale(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := rtp.NewSession(rtp.Setup{
+		Interleaved: []int{0, 1},
+		Writer:      discardWriter{},
+		ClockRate:   90000,
+	})
+
+	assert.Nil(err)
+	defer r.Close()
+
+	_, offset := r.Next(0)
+	_, ts := r.Next(90000)
+	assert.Equal(offset+90000, ts)
+
+	// Twice as fast: 2s of media are presented in 1s
+	r.SetScale(2, 90000)
+	_, ts = r.Next(270000)
+	assert.Equal(offset+180000, ts)
+
+	// Backwards from the 3s mark, timestamps keep increasing
+	r.SetScale(-1, 270000)
+	_, ts = r.Next(180000)
+	assert.Equal(offset+270000, ts)
+
+	// Back to normal speed
+	r.SetScale(1, 180000)
+	_, ts = r.Next(270000)
+	assert.Equal(offset+360000, ts)
+}
+
+type captureWriter chan []byte
+
+func (c captureWriter) WriteInterleaved(channel int, payload []byte) error {
+	if channel == 0 {
+		c <- payload
+	}
+
+	return nil
+}
+
+func TestSessionForward(t *testing.T) {
+	assert := assert.New(t)
+	frames := make(captureWriter, 16)
+
+	r, err := rtp.NewSession(rtp.Setup{
+		Interleaved: []int{0, 1},
+		Writer:      frames,
+		ClockRate:   90000,
+	})
+
+	assert.Nil(err)
+	defer r.Close()
+
+	source := []*rtp.Packet{
+		{Sequence: 10, Timestamp: 500, Payload: []byte{1}},
+		{Sequence: 11, Timestamp: 1000, Payload: []byte{2}},
+		{Sequence: 13, Timestamp: 2000, Payload: []byte{3}, Marker: true},
+	}
+
+	// Nothing is sent before playing
+	r.Forward(source[0], 0, true)
+	r.Play()
+	seq, ts := r.Next(1000)
+
+	// Packets before the first sync point are discarded
+	r.Forward(source[0], 0, false)
+	r.Forward(source[1], 1000, true)
+	r.Forward(source[2], 2000, false)
+
+	var received []rtp.Packet
+
+	for i := 0; i < 2; i++ {
+		var p rtp.Packet
+		assert.Nil(p.Unmarshal(<-frames))
+		received = append(received, p)
+	}
+
+	assert.Equal(r.SSRC(), received[0].SSRC)
+	assert.Equal(seq, received[0].Sequence)
+	assert.Equal(ts, received[0].Timestamp)
+	assert.Equal([]byte{2}, received[0].Payload)
+
+	// The source loss is kept
+	assert.Equal(seq+2, received[1].Sequence)
+	assert.Equal(ts+1000, received[1].Timestamp)
+	assert.True(received[1].Marker)
+}
+
+func TestSessionResync(t *testing.T) {
+	assert := assert.New(t)
+	frames := make(captureWriter, 16)
+
+	r, err := rtp.NewSession(rtp.Setup{
+		Interleaved: []int{0, 1},
+		Writer:      frames,
+		ClockRate:   90000,
+	})
+
+	assert.Nil(err)
+	defer r.Close()
+
+	r.Play()
+	r.Forward(&rtp.Packet{Sequence: 100, Payload: []byte{1}}, 0, true)
+
+	// A new source session, with its own sequence numbers
+	r.Resync()
+	r.Forward(&rtp.Packet{Sequence: 7, Payload: []byte{2}}, 3000, false)
+	r.Forward(&rtp.Packet{Sequence: 8, Payload: []byte{3}}, 3000, true)
+
+	var first, second rtp.Packet
+	assert.Nil(first.Unmarshal(<-frames))
+	assert.Nil(second.Unmarshal(<-frames))
+
+	assert.Equal(first.Sequence+1, second.Sequence)
+	assert.Equal([]byte{3}, second.Payload)
+}
+
+func TestParseAddr(t *testing.T) {
+	assert := assert.New(t)
+
+	ip, zone := rtp.ParseAddr("192.168.0.10")
+	assert.Equal("192.168.0.10", ip.String())
+	assert.Equal("", zone)
+
+	ip, zone = rtp.ParseAddr("fe80::1%eth0")
+	assert.Equal("fe80::1", ip.String())
+	assert.Equal("eth0", zone)
+
+	ip, _ = rtp.ParseAddr("")
+	assert.Nil(ip)
+}
+
+func TestSessionSRTP(t *testing.T) {
+	assert := assert.New(t)
+	frames := make(captureWriter, 16)
+	key := make([]byte, 30)
+
+	sender, err := rtp.NewSession(rtp.Setup{
+		Interleaved: []int{0, 1},
+		Writer:      frames,
+		ClockRate:   90000,
+		SRTPKey:     key,
+	})
+
+	assert.Nil(err)
+	defer sender.Close()
+
+	received := make(chan *rtp.Packet, 1)
+	receiver, err := rtp.NewSession(rtp.Setup{
+		Interleaved: []int{0, 1},
+		Writer:      discardWriter{},
+		SRTPKey:     key,
+		Receive: func(p *rtp.Packet) {
+			received <- p
+		},
+	})
+
+	assert.Nil(err)
+	defer receiver.Close()
+
+	sender.Play()
+	sender.WritePayloads(0, [][]byte{{1, 2, 3, 4}})
+	b := <-frames
+
+	// Only the payload is encrypted, followed by the authentication tag
+	var p rtp.Packet
+	assert.Nil(p.Unmarshal(b))
+	assert.Equal(sender.SSRC(), p.SSRC)
+	assert.Len(p.Payload, 4+10)
+	assert.NotEqual([]byte{1, 2, 3, 4}, p.Payload[:4])
+
+	receiver.HandleInterleaved(0, b)
+	assert.Equal([]byte{1, 2, 3, 4}, (<-received).Payload)
+
+	// Modified packets are discarded
+	b[len(b)-1] ^= 1
+	receiver.HandleInterleaved(0, b)
+	assert.Len(received, 0)
+
+	_, err = rtp.NewSession(rtp.Setup{
+		Interleaved: []int{0, 1},
+		Writer:      discardWriter{},
+		SRTPKey:     key[:16],
+	})
+
+	assert.NotNil(err)
+}
+
+func TestSessionPeer(t *testing.T) {
+	assert := assert.New(t)
+	received := make(chan *rtp.Packet, 4)
+	localhost := net.ParseIP("127.0.0.1")
+
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: localhost})
+	assert.Nil(err)
+	defer peer.Close()
+
+	other, err := net.ListenUDP("udp", &net.UDPAddr{IP: localhost})
+	assert.Nil(err)
+	defer other.Close()
+
+	port := peer.LocalAddr().(*net.UDPAddr).Port
+	r, err := rtp.NewSession(rtp.Setup{
+		ServerPort:  46000,
+		ServerAddr:  "127.0.0.1",
+		ClientAddr:  "127.0.0.1",
+		ClientPorts: []int{port, port + 1},
+		Receive: func(p *rtp.Packet) {
+			received <- p
+		},
+	})
+
+	assert.Nil(err)
+	defer r.Close()
+
+	server := &net.UDPAddr{IP: localhost, Port: 46000}
+
+	// Packets from anyone but the peer are discarded
+	other.WriteToUDP((&rtp.Packet{Sequence: 1}).Marshal(), server)
+	peer.WriteToUDP((&rtp.Packet{Sequence: 2}).Marshal(), server)
+
+	select {
+	case p := <-received:
+		assert.Equal(uint16(2), p.Sequence)
+
+	case <-time.After(time.Second):
+		assert.Fail("packet not received")
+	}
+
+	assert.Len(received, 0)
+}
+
+func TestSessionMulticast(t *testing.T) {
+	assert := assert.New(t)
+	received := make(chan struct{}, 4)
+	localhost := net.ParseIP("127.0.0.1")
+
+	other, err := net.ListenUDP("udp", &net.UDPAddr{IP: localhost})
+	assert.Nil(err)
+	defer other.Close()
+
+	r, err := rtp.NewSession(rtp.Setup{
+		ServerPort:  46002,
+		ServerAddr:  "127.0.0.1",
+		ClientAddr:  "239.0.0.1",
+		ClientPorts: []int{5004, 5005},
+		Receive: func(p *rtp.Packet) {
+			received <- struct{}{}
+		},
+		Activity: func() {
+			received <- struct{}{}
+		},
+	})
+
+	if !assert.Nil(err) {
+		return
+	}
+
+	defer r.Close()
+
+	// Group members aren't known, so nothing sent to the session is used
+	other.WriteToUDP((&rtp.Packet{Sequence: 1}).Marshal(), &net.UDPAddr{IP: localhost, Port: 46002})
+	other.WriteToUDP([]byte{0x81, 0xc9, 0x00, 0x01, 0, 0, 0, 1}, &net.UDPAddr{IP: localhost, Port: 46003})
+
+	select {
+	case <-received:
+		assert.Fail("packet received")
+
+	case <-time.After(200 * time.Millisecond):
+	}
+}
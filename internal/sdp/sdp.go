@@ -6,19 +6,54 @@
 package sdp
 
 import (
+	"errors"
+	"fmt"
 	"net"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gortc/sdp"
 )
 
 type MediaSetup struct {
+	// Type is the media type, i.e, video or audio.
+	Type string
 	Port int
+
+	// PayloadType, Encoding and Fmtp describe the media RTP payload format,
+	// where Encoding is in the rtpmap format (H264/90000, for example) and
+	// Fmtp holds format specific parameters.
+	PayloadType int
+	Encoding    string
+	Fmtp        string
+
+	// Control is the media URL, relative to the session one, used by
+	// clients to setup it.
+	Control string
+
+	// Crypto holds the SDES crypto attributes (RFC 4568) announcing the
+	// keys of the media. If used, the media uses the SRTP profile
+	// (RTP/SAVP).
+	Crypto []string
 }
 
 type Setup struct {
-	Video      MediaSetup
+	Medias     []MediaSetup
 	ClientHost string
 
+	// Origin is the address of the host which created the session. If not
+	// set, 127.0.0.1 is used.
+	Origin string
+
+	// Duration, if known, is announced so clients may seek inside the
+	// session.
+	Duration time.Duration
+
+	// TTL is the time-to-live of the session packets when ClientHost is a
+	// multicast group.
+	TTL int
+
 	message *sdp.Message
 }
 
@@ -34,29 +69,71 @@ func (s *Session) Bytes() []byte {
 	return s.session.AppendTo(b)
 }
 
-func NewSession(options Setup) *Session {
-	// video
-	video := sdp.Media{
+func newMedia(options MediaSetup) sdp.Media {
+	payloadType := fmt.Sprintf("%d", options.PayloadType)
+	protocol := "RTP/AVP"
+
+	if len(options.Crypto) > 0 {
+		protocol = "RTP/SAVP"
+	}
+
+	media := sdp.Media{
 		Description: sdp.MediaDescription{
-			Type:     "video",
-			Port:     options.Video.Port,
-			Formats:  []string{"99"},
-			Protocol: "RTP/AVP",
+			Type:     options.Type,
+			Port:     options.Port,
+			Formats:  []string{payloadType},
+			Protocol: protocol,
 		},
 	}
 
-	video.AddAttribute("rtpmap", "99", "h263-1998/90000")
+	media.AddAttribute("rtpmap", payloadType, options.Encoding)
+
+	if options.Fmtp != "" {
+		media.AddAttribute("fmtp", payloadType, options.Fmtp)
+	}
+
+	if options.Control != "" {
+		media.AddAttribute("control", options.Control)
+	}
+
+	for _, c := range options.Crypto {
+		media.AddAttribute("crypto", c)
+	}
+
+	return media
+}
+
+func NewSession(options Setup) *Session {
+	var medias []sdp.Media
+
+	for _, m := range options.Medias {
+		medias = append(medias, newMedia(m))
+	}
+
+	origin := options.Origin
+
+	if origin == "" {
+		origin = "127.0.0.1"
+	}
 
 	// message
 	message := &sdp.Message{
 		Origin: sdp.Origin{
-			Address: "127.0.0.1",
+			Username: "-",
+			Address:  origin,
 		},
 		Connection: sdp.ConnectionData{
-			IP: net.ParseIP(options.ClientHost),
+			IP:  net.ParseIP(options.ClientHost),
+			TTL: byte(options.TTL),
 		},
 		Name:   "video forwarding",
-		Medias: []sdp.Media{video},
+		Medias: medias,
+	}
+
+	message.AddAttribute("control", "*")
+
+	if options.Duration > 0 {
+		message.AddAttribute("range", fmt.Sprintf("npt=0-%.3f", options.Duration.Seconds()))
 	}
 
 	// session
@@ -68,3 +145,57 @@ func NewSession(options Setup) *Session {
 		Setup:   options,
 	}
 }
+
+// Decode parses a session description, such as the one announced by a
+// client publishing a stream, giving its medias. Only the first payload
+// format of each media is considered.
+func Decode(b []byte) (*Setup, error) {
+	session, err := sdp.DecodeSession(b, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var message sdp.Message
+	decoder := sdp.NewDecoder(session)
+
+	if err := decoder.Decode(&message); err != nil {
+		return nil, err
+	}
+
+	setup := &Setup{
+		message: &message,
+	}
+
+	for _, m := range message.Medias {
+		if len(m.Description.Formats) == 0 {
+			return nil, errors.New("media without payload formats")
+		}
+
+		format := m.Description.Formats[0]
+		payloadType, err := strconv.Atoi(format)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload type %q", format)
+		}
+
+		media := MediaSetup{
+			Type:        m.Description.Type,
+			Port:        m.Description.Port,
+			PayloadType: payloadType,
+			Encoding:    m.PayloadFormat(format),
+			Control:     m.Attribute("control"),
+			Crypto:      m.Attributes.Values("crypto"),
+		}
+
+		for _, fmtp := range m.Attributes.Values("fmtp") {
+			if f := strings.SplitN(fmtp, " ", 2); len(f) == 2 && f[0] == format {
+				media.Fmtp = strings.TrimSpace(f[1])
+			}
+		}
+
+		setup.Medias = append(setup.Medias, media)
+	}
+
+	return setup, nil
+}
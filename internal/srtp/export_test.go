@@ -0,0 +1,16 @@
+//
+// Description: Internals checked against the RFC 3711 test vectors.
+//
+package srtp
+
+var (
+	DeriveKey    = deriveKey
+	RTPIV        = rtpIV
+	XORKeyStream = xorKeyStream
+)
+
+const (
+	LabelRTPEncryption = labelRTPEncryption
+	LabelRTPAuth       = labelRTPAuth
+	LabelRTPSalt       = labelRTPSalt
+)
@@ -0,0 +1,536 @@
+//
+// Description: Secure RTP (RFC 3711).
+//
+package srtp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Suite is the only crypto suite supported, which is the default one of
+// SRTP.
+const Suite = "AES_CM_128_HMAC_SHA1_80"
+
+const (
+	// MasterKeyLength is the length of the master key followed by the
+	// master salt, as exchanged by SDES.
+	MasterKeyLength = keyLength + saltLength
+
+	keyLength     = 16
+	saltLength    = 14
+	authKeyLength = 20
+	authTagLength = 10
+
+	rtpHeaderLength  = 12
+	rtcpHeaderLength = 8
+
+	// srtcpEncrypted is the E flag of the SRTCP index, telling that the
+	// packet payload is encrypted.
+	srtcpEncrypted = 1 << 31
+
+	// replayWindowSize is the number of packets before the highest index
+	// received which are still accepted, if not received yet.
+	replayWindowSize = 64
+)
+
+// Key derivation labels (RFC 3711 section 4.3.1)
+const (
+	labelRTPEncryption  = 0x00
+	labelRTPAuth        = 0x01
+	labelRTPSalt        = 0x02
+	labelRTCPEncryption = 0x03
+	labelRTCPAuth       = 0x04
+	labelRTCPSalt       = 0x05
+)
+
+var (
+	errShortPacket = errors.New("srtp: packet too short")
+	errAuth        = errors.New("srtp: authentication failed")
+	errReplay      = errors.New("srtp: replayed packet")
+)
+
+// sessionKeys are the keys of one kind of packet (RTP or RTCP), derived
+// from the master key.
+type sessionKeys struct {
+	block cipher.Block
+	salt  []byte
+	auth  []byte
+}
+
+// Context protects the packets sent by, or received from, the sources of a
+// SRTP session. Each direction must use its own context, since it keeps
+// the packet indexes of the sources.
+type Context struct {
+	rtp  sessionKeys
+	rtcp sessionKeys
+
+	lock sync.Mutex
+
+	// Rollover counters and highest sequence numbers of the RTP sources,
+	// and the SRTCP index of each RTCP source.
+	rollover  map[uint32]uint32
+	sequence  map[uint32]uint16
+	rtcpIndex map[uint32]uint32
+
+	// Replay lists of the packets received from each source.
+	rtpReplay  map[uint32]*replayWindow
+	rtcpReplay map[uint32]*replayWindow
+}
+
+// replayWindow is the replay list of a source (RFC 3711 section 3.3.2). It
+// holds the highest packet index received and a bitmask of the ones before
+// it that were received as well.
+type replayWindow struct {
+	highest uint64
+	mask    uint64
+}
+
+// accept tells if a packet index wasn't received yet and is recent enough,
+// marking it as received.
+func (w *replayWindow) accept(index uint64) bool {
+	if index > w.highest {
+		if shift := index - w.highest; shift < replayWindowSize {
+			w.mask = w.mask<<shift | 1
+		} else {
+			w.mask = 1
+		}
+
+		w.highest = index
+
+		return true
+	}
+
+	diff := w.highest - index
+
+	if diff >= replayWindowSize || w.mask&(1<<diff) != 0 {
+		return false
+	}
+
+	w.mask |= 1 << diff
+
+	return true
+}
+
+// EncryptRTP gives the SRTP packet of a RTP packet.
+func (c *Context) EncryptRTP(b []byte) ([]byte, error) {
+	n, err := rtpHeaderSize(b)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ssrc := binary.BigEndian.Uint32(b[8:12])
+	sequence := binary.BigEndian.Uint16(b[2:4])
+	rollover := c.sendRollover(ssrc, sequence)
+
+	out := make([]byte, len(b), len(b)+authTagLength)
+	copy(out, b[:n])
+	xorKeyStream(c.rtp.block, rtpIV(c.rtp.salt, ssrc, rollover, sequence), out[n:], b[n:])
+
+	return append(out, c.rtpTag(out, rollover)...), nil
+}
+
+// DecryptRTP gives the RTP packet of a SRTP packet, after checking that it
+// wasn't modified.
+func (c *Context) DecryptRTP(b []byte) ([]byte, error) {
+	if len(b) < rtpHeaderLength+authTagLength {
+		return nil, errShortPacket
+	}
+
+	packet, tag := b[:len(b)-authTagLength], b[len(b)-authTagLength:]
+	n, err := rtpHeaderSize(packet)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ssrc := binary.BigEndian.Uint32(packet[8:12])
+	sequence := binary.BigEndian.Uint16(packet[2:4])
+	rollover := c.receiveRollover(ssrc, sequence)
+
+	if subtle.ConstantTimeCompare(tag, c.rtpTag(packet, rollover)) != 1 {
+		return nil, errAuth
+	}
+
+	if !c.updateRollover(ssrc, rollover, sequence) {
+		return nil, errReplay
+	}
+
+	out := make([]byte, len(packet))
+	copy(out, packet[:n])
+	xorKeyStream(c.rtp.block, rtpIV(c.rtp.salt, ssrc, rollover, sequence), out[n:], packet[n:])
+
+	return out, nil
+}
+
+// EncryptRTCP gives the SRTCP packet of a (compound) RTCP packet.
+func (c *Context) EncryptRTCP(b []byte) ([]byte, error) {
+	if len(b) < rtcpHeaderLength {
+		return nil, errShortPacket
+	}
+
+	ssrc := binary.BigEndian.Uint32(b[4:8])
+	index := c.nextRTCPIndex(ssrc)
+
+	out := make([]byte, len(b), len(b)+4+authTagLength)
+	copy(out, b[:rtcpHeaderLength])
+	xorKeyStream(c.rtcp.block, rtcpIV(c.rtcp.salt, ssrc, index), out[rtcpHeaderLength:], b[rtcpHeaderLength:])
+
+	var e [4]byte
+	binary.BigEndian.PutUint32(e[:], index|srtcpEncrypted)
+	out = append(out, e[:]...)
+
+	return append(out, c.rtcpTag(out)...), nil
+}
+
+// DecryptRTCP gives the (compound) RTCP packet of a SRTCP packet, after
+// checking that it wasn't modified.
+func (c *Context) DecryptRTCP(b []byte) ([]byte, error) {
+	if len(b) < rtcpHeaderLength+4+authTagLength {
+		return nil, errShortPacket
+	}
+
+	packet, tag := b[:len(b)-authTagLength], b[len(b)-authTagLength:]
+
+	if subtle.ConstantTimeCompare(tag, c.rtcpTag(packet)) != 1 {
+		return nil, errAuth
+	}
+
+	e := binary.BigEndian.Uint32(packet[len(packet)-4:])
+	packet = packet[:len(packet)-4]
+	ssrc := binary.BigEndian.Uint32(packet[4:8])
+
+	if !c.acceptRTCPIndex(ssrc, e&^srtcpEncrypted) {
+		return nil, errReplay
+	}
+
+	out := append([]byte(nil), packet...)
+
+	if e&srtcpEncrypted != 0 {
+		iv := rtcpIV(c.rtcp.salt, ssrc, e&^srtcpEncrypted)
+		xorKeyStream(c.rtcp.block, iv, out[rtcpHeaderLength:], packet[rtcpHeaderLength:])
+	}
+
+	return out, nil
+}
+
+// sendRollover gives the rollover counter of a packet sent by a source,
+// which is incremented whenever its sequence number wraps around.
+func (c *Context) sendRollover(ssrc uint32, sequence uint16) uint32 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	last, ok := c.sequence[ssrc]
+
+	if ok && sequence < last && last-sequence > 0x8000 {
+		c.rollover[ssrc]++
+	}
+
+	if !ok || int16(sequence-last) > 0 {
+		c.sequence[ssrc] = sequence
+	}
+
+	return c.rollover[ssrc]
+}
+
+// receiveRollover estimates the rollover counter of a packet received from
+// a source (RFC 3711 section 3.3.1).
+func (c *Context) receiveRollover(ssrc uint32, sequence uint16) uint32 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	last, ok := c.sequence[ssrc]
+	rollover := c.rollover[ssrc]
+
+	if !ok {
+		return 0
+	}
+
+	if last < 0x8000 {
+		if sequence > last && sequence-last > 0x8000 {
+			return rollover - 1
+		}
+	} else if last-0x8000 > sequence {
+		return rollover + 1
+	}
+
+	return rollover
+}
+
+// updateRollover keeps the rollover counter and the highest sequence number
+// received from a source, after its packet was authenticated. It tells if
+// the packet must be accepted, i.e., it is not a replay.
+func (c *Context) updateRollover(ssrc, rollover uint32, sequence uint16) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	w, ok := c.rtpReplay[ssrc]
+
+	if !ok {
+		w = &replayWindow{}
+		c.rtpReplay[ssrc] = w
+	}
+
+	if !w.accept(uint64(rollover)<<16 | uint64(sequence)) {
+		return false
+	}
+
+	last, ok := c.sequence[ssrc]
+
+	switch {
+	case !ok, rollover == c.rollover[ssrc]+1:
+		c.rollover[ssrc] = rollover
+		c.sequence[ssrc] = sequence
+
+	case rollover == c.rollover[ssrc] && sequence > last:
+		c.sequence[ssrc] = sequence
+	}
+
+	return true
+}
+
+// acceptRTCPIndex tells if a SRTCP packet received from a source, after it
+// was authenticated, must be accepted, i.e., it is not a replay.
+func (c *Context) acceptRTCPIndex(ssrc, index uint32) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	w, ok := c.rtcpReplay[ssrc]
+
+	if !ok {
+		w = &replayWindow{}
+		c.rtcpReplay[ssrc] = w
+	}
+
+	return w.accept(uint64(index))
+}
+
+// nextRTCPIndex gives the SRTCP index of the next packet sent by a source.
+func (c *Context) nextRTCPIndex(ssrc uint32) uint32 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	index := c.rtcpIndex[ssrc]
+	c.rtcpIndex[ssrc] = (index + 1) &^ srtcpEncrypted
+
+	return index
+}
+
+// rtpTag gives the authentication tag of a SRTP packet.
+func (c *Context) rtpTag(packet []byte, rollover uint32) []byte {
+	var roc [4]byte
+	binary.BigEndian.PutUint32(roc[:], rollover)
+
+	mac := hmac.New(sha1.New, c.rtp.auth)
+	mac.Write(packet)
+	mac.Write(roc[:])
+
+	return mac.Sum(nil)[:authTagLength]
+}
+
+// rtcpTag gives the authentication tag of a SRTCP packet, including its
+// index.
+func (c *Context) rtcpTag(packet []byte) []byte {
+	mac := hmac.New(sha1.New, c.rtcp.auth)
+	mac.Write(packet)
+
+	return mac.Sum(nil)[:authTagLength]
+}
+
+// rtpHeaderSize gives the length of a RTP packet header, with its CSRC list
+// and extension.
+func rtpHeaderSize(b []byte) (int, error) {
+	if len(b) < rtpHeaderLength {
+		return 0, errShortPacket
+	}
+
+	n := rtpHeaderLength + 4*int(b[0]&0x0f)
+
+	if b[0]&0x10 != 0 {
+		if len(b) < n+4 {
+			return 0, errShortPacket
+		}
+
+		n += 4 + 4*int(binary.BigEndian.Uint16(b[n+2:n+4]))
+	}
+
+	if len(b) < n {
+		return 0, errShortPacket
+	}
+
+	return n, nil
+}
+
+// rtpIV gives the counter of the first keystream block of a SRTP packet:
+// salt * 2^16 XOR SSRC * 2^64 XOR index * 2^16.
+func rtpIV(salt []byte, ssrc, rollover uint32, sequence uint16) []byte {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, salt)
+
+	var index [8]byte
+	binary.BigEndian.PutUint32(index[2:6], rollover)
+	binary.BigEndian.PutUint16(index[6:8], sequence)
+
+	xorSSRC(iv, ssrc)
+
+	for i := 0; i < 6; i++ {
+		iv[8+i] ^= index[2+i]
+	}
+
+	return iv
+}
+
+// rtcpIV gives the counter of the first keystream block of a SRTCP packet.
+func rtcpIV(salt []byte, ssrc, index uint32) []byte {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, salt)
+	xorSSRC(iv, ssrc)
+
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], index)
+
+	for i := 0; i < 4; i++ {
+		iv[10+i] ^= b[i]
+	}
+
+	return iv
+}
+
+func xorSSRC(iv []byte, ssrc uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], ssrc)
+
+	for i := 0; i < 4; i++ {
+		iv[4+i] ^= b[i]
+	}
+}
+
+// xorKeyStream encrypts (or decrypts) src into dst using AES in counter
+// mode.
+func xorKeyStream(block cipher.Block, iv, dst, src []byte) {
+	cipher.NewCTR(block, iv).XORKeyStream(dst, src)
+}
+
+// deriveKey gives a session key of length n from the master key (RFC 3711
+// section 4.3.1), with a key derivation rate of zero.
+func deriveKey(master cipher.Block, salt []byte, label byte, n int) []byte {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, salt)
+	iv[7] ^= label
+
+	key := make([]byte, n)
+	xorKeyStream(master, iv, key, key)
+
+	return key
+}
+
+func newSessionKeys(master cipher.Block, salt []byte, encryption, auth, saltLabel byte) (sessionKeys, error) {
+	block, err := aes.NewCipher(deriveKey(master, salt, encryption, keyLength))
+
+	if err != nil {
+		return sessionKeys{}, err
+	}
+
+	return sessionKeys{
+		block: block,
+		salt:  deriveKey(master, salt, saltLabel, saltLength),
+		auth:  deriveKey(master, salt, auth, authKeyLength),
+	}, nil
+}
+
+// NewContext creates a context from a master key followed by its master
+// salt, as given by GenerateKey.
+func NewContext(key []byte) (*Context, error) {
+	if len(key) != MasterKeyLength {
+		return nil, fmt.Errorf("srtp: invalid master key length %d", len(key))
+	}
+
+	master, err := aes.NewCipher(key[:keyLength])
+
+	if err != nil {
+		return nil, err
+	}
+
+	salt := key[keyLength:]
+	c := &Context{
+		rollover:   make(map[uint32]uint32),
+		sequence:   make(map[uint32]uint16),
+		rtcpIndex:  make(map[uint32]uint32),
+		rtpReplay:  make(map[uint32]*replayWindow),
+		rtcpReplay: make(map[uint32]*replayWindow),
+	}
+
+	if c.rtp, err = newSessionKeys(master, salt, labelRTPEncryption, labelRTPAuth, labelRTPSalt); err != nil {
+		return nil, err
+	}
+
+	if c.rtcp, err = newSessionKeys(master, salt, labelRTCPEncryption, labelRTCPAuth, labelRTCPSalt); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// GenerateKey gives a random master key followed by its master salt.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, MasterKeyLength)
+
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// CryptoAttribute gives the SDES crypto attribute value (RFC 4568) which
+// announces a master key inside a session description.
+func CryptoAttribute(tag int, key []byte) string {
+	return fmt.Sprintf("%d %s inline:%s", tag, Suite, base64.StdEncoding.EncodeToString(key))
+}
+
+// ParseCryptoAttribute gives the master key announced by a SDES crypto
+// attribute value. It fails if the attribute uses an unsupported crypto
+// suite.
+func ParseCryptoAttribute(value string) ([]byte, error) {
+	f := strings.Fields(value)
+
+	if len(f) < 3 {
+		return nil, errors.New("srtp: invalid crypto attribute")
+	}
+
+	if f[1] != Suite {
+		return nil, fmt.Errorf("srtp: unsupported crypto suite %s", f[1])
+	}
+
+	// Key parameters may carry a lifetime and MKI after the key, and
+	// several keys are separated by ';'
+	params := strings.Split(f[2], ";")[0]
+
+	if !strings.HasPrefix(params, "inline:") {
+		return nil, errors.New("srtp: unsupported key method")
+	}
+
+	encoded := strings.Split(strings.TrimPrefix(params, "inline:"), "|")[0]
+	key, err := base64.StdEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != MasterKeyLength {
+		return nil, fmt.Errorf("srtp: invalid master key length %d", len(key))
+	}
+
+	return key, nil
+}
@@ -0,0 +1,210 @@
+//
+// Description: Secure RTP tests.
+//
+package srtp_test
+
+import (
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/rsfreitas/go-rtsp/internal/srtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeHex(s string) []byte {
+	b, _ := hex.DecodeString(s)
+	return b
+}
+
+func testKey() []byte {
+	key := make([]byte, srtp.MasterKeyLength)
+
+	for i := range key {
+		if i < 16 {
+			key[i] = byte(i)
+		} else {
+			key[i] = byte(84 + i)
+		}
+	}
+
+	return key
+}
+
+func rtpPacket(sequence uint16) []byte {
+	return []byte{
+		0x80, 0x60, byte(sequence >> 8), byte(sequence), 0x00, 0x00, 0x10, 0x00,
+		0xde, 0xad, 0xbe, 0xef, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+	}
+}
+
+func TestKeyStream(t *testing.T) {
+	assert := assert.New(t)
+
+	// RFC 3711 appendix B.2
+	block, err := aes.NewCipher(decodeHex("2b7e151628aed2a6abf7158809cf4f3c"))
+	assert.Nil(err)
+
+	iv := srtp.RTPIV(decodeHex("f0f1f2f3f4f5f6f7f8f9fafbfcfd"), 0, 0, 0)
+	assert.Equal("f0f1f2f3f4f5f6f7f8f9fafbfcfd0000", hex.EncodeToString(iv))
+
+	keyStream := make([]byte, 48)
+	srtp.XORKeyStream(block, iv, keyStream, keyStream)
+
+	assert.Equal("e03ead0935c95e80e166b16dd92b4eb4"+
+		"d23513162b02d0f72a43a2fe4a5f97ab"+
+		"41e95b3bb0a2e8dd477901e4fca894c0", hex.EncodeToString(keyStream))
+}
+
+func TestDeriveKey(t *testing.T) {
+	assert := assert.New(t)
+
+	// RFC 3711 appendix B.3
+	master, err := aes.NewCipher(decodeHex("e1f97a0d3e018be0d64fa32c06de4139"))
+	assert.Nil(err)
+
+	salt := decodeHex("0ec675ad498afeebb6960b3aabe6")
+
+	assert.Equal("c61e7a93744f39ee10734afe3ff7a087",
+		hex.EncodeToString(srtp.DeriveKey(master, salt, srtp.LabelRTPEncryption, 16)))
+
+	assert.Equal("30cbbc08863d8c85d49db34a9ae1",
+		hex.EncodeToString(srtp.DeriveKey(master, salt, srtp.LabelRTPSalt, 14)))
+
+	assert.Equal("cebe321f6ff7716b6fd4ab49af256a156d38baa4",
+		hex.EncodeToString(srtp.DeriveKey(master, salt, srtp.LabelRTPAuth, 20)))
+}
+
+func TestRTP(t *testing.T) {
+	assert := assert.New(t)
+
+	sender, err := srtp.NewContext(testKey())
+	assert.Nil(err)
+
+	receiver, err := srtp.NewContext(testKey())
+	assert.Nil(err)
+
+	// The rollover counter is incremented when the sequence number wraps
+	// around
+	packets := []struct {
+		sequence uint16
+		srtp     string
+	}{
+		{0xfffe, "8060fffe00001000deadbeef5a88deb5c3f71d82e25ea27cb36b3dab34a6"},
+		{0xffff, "8060ffff00001000deadbeef084267befd9136c5a8950b5eeddf2025af26"},
+		{0x0000, "8060000000001000deadbeef6fcfccd2e3843409d8bd444163aa16b215ff"},
+		{0x0001, "8060000100001000deadbeeffcacd2493e4cecccb2ea63a185e079c33aa0"},
+	}
+
+	for _, p := range packets {
+		b, err := sender.EncryptRTP(rtpPacket(p.sequence))
+		assert.Nil(err)
+		assert.Equal(p.srtp, hex.EncodeToString(b))
+
+		b, err = receiver.DecryptRTP(decodeHex(p.srtp))
+		assert.Nil(err)
+		assert.Equal(rtpPacket(p.sequence), b)
+	}
+
+	b := decodeHex(packets[3].srtp)
+	b[len(b)-1] ^= 1
+	_, err = receiver.DecryptRTP(b)
+	assert.NotNil(err)
+
+	_, err = receiver.DecryptRTP(b[:12])
+	assert.NotNil(err)
+
+	// Replayed packets are refused
+	_, err = receiver.DecryptRTP(decodeHex(packets[3].srtp))
+	assert.NotNil(err)
+}
+
+func TestRTPReplay(t *testing.T) {
+	assert := assert.New(t)
+
+	sender, err := srtp.NewContext(testKey())
+	assert.Nil(err)
+
+	receiver, err := srtp.NewContext(testKey())
+	assert.Nil(err)
+
+	packets := make(map[uint16][]byte)
+
+	for sequence := uint16(10); sequence <= 100; sequence++ {
+		packets[sequence], err = sender.EncryptRTP(rtpPacket(sequence))
+		assert.Nil(err)
+	}
+
+	for _, sequence := range []uint16{100, 50, 37} {
+		b, err := receiver.DecryptRTP(packets[sequence])
+		assert.Nil(err)
+		assert.Equal(rtpPacket(sequence), b)
+	}
+
+	// Packets already received, or too old to be told apart from the
+	// ones already received
+	for _, sequence := range []uint16{100, 50, 36, 10} {
+		_, err := receiver.DecryptRTP(packets[sequence])
+		assert.NotNil(err)
+	}
+}
+
+func TestRTCP(t *testing.T) {
+	assert := assert.New(t)
+
+	sender, err := srtp.NewContext(testKey())
+	assert.Nil(err)
+
+	receiver, err := srtp.NewContext(testKey())
+	assert.Nil(err)
+
+	rtcp := decodeHex("80c80006deadbeef0102030405060708090a0b0c0d0e0f1011121314")
+	var packets [][]byte
+
+	for i := 0; i < 2; i++ {
+		b, err := sender.EncryptRTCP(rtcp)
+		assert.Nil(err)
+		assert.Len(b, len(rtcp)+4+10)
+		assert.Equal([]byte{0x80, 0, 0, byte(i)}, b[len(rtcp):len(rtcp)+4])
+		packets = append(packets, b)
+	}
+
+	// Packet with SRTCP index 1
+	assert.Equal("80c80006deadbeef470752094b6928605477c522"+
+		"e363349dc07d153e80000001678bd9dc860efdae2d09", hex.EncodeToString(packets[1]))
+
+	// Packets are accepted out of order, but only once
+	for _, p := range [][]byte{packets[1], packets[0]} {
+		b, err := receiver.DecryptRTCP(p)
+		assert.Nil(err)
+		assert.Equal(rtcp, b)
+	}
+
+	_, err = receiver.DecryptRTCP(packets[1])
+	assert.NotNil(err)
+}
+
+func TestCryptoAttribute(t *testing.T) {
+	assert := assert.New(t)
+
+	s := srtp.CryptoAttribute(1, testKey())
+	assert.Equal("1 AES_CM_128_HMAC_SHA1_80 inline:AAECAwQFBgcICQoLDA0OD2RlZmdoaWprbG1ub3Bx", s)
+
+	key, err := srtp.ParseCryptoAttribute(s)
+	assert.Nil(err)
+	assert.Equal(testKey(), key)
+
+	key, err = srtp.ParseCryptoAttribute("1 AES_CM_128_HMAC_SHA1_80 " +
+		"inline:AAECAwQFBgcICQoLDA0OD2RlZmdoaWprbG1ub3Bx|2^20|1:4")
+
+	assert.Nil(err)
+	assert.Equal(testKey(), key)
+
+	_, err = srtp.ParseCryptoAttribute("1 AES_CM_128_HMAC_SHA1_32 " +
+		"inline:AAECAwQFBgcICQoLDA0OD2RlZmdoaWprbG1ub3Bx")
+
+	assert.NotNil(err)
+
+	_, err = srtp.NewContext(testKey()[:16])
+	assert.NotNil(err)
+}
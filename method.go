@@ -25,6 +25,24 @@ const (
 	methodSetParameter
 )
 
+var methodNames = map[methodType]string{
+	methodOptions:      "OPTIONS",
+	methodDescribe:     "DESCRIBE",
+	methodSetup:        "SETUP",
+	methodPlay:         "PLAY",
+	methodPause:        "PAUSE",
+	methodTeardown:     "TEARDOWN",
+	methodRecord:       "RECORD",
+	methodAnnounce:     "ANNOUNCE",
+	methodGetParameter: "GET_PARAMETER",
+	methodSetParameter: "SET_PARAMETER",
+}
+
+// String gives the method name, as used inside requests.
+func (m methodType) String() string {
+	return methodNames[m]
+}
+
 // method defines a method set of functions it must have to be internally
 // supported.
 type method interface {
@@ -33,8 +51,10 @@ type method interface {
 	Verify(*packet.Packet, interface{}) error
 
 	// Handle should call the method handler function and it also should be
-	// responsible for preparing the Response for the client.
-	Handle(*packet.Packet)
+	// responsible for preparing the Response for the client. The Request
+	// is the public representation of the packet request, which is passed
+	// to the handler function.
+	Handle(*packet.Packet, *Request)
 
 	// Type must return the method identification
 	Type() methodType
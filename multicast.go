@@ -0,0 +1,235 @@
+//
+// Description: Multicast delivery of streams.
+//
+package rtsp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/rsfreitas/go-rtsp/internal/adt"
+	"github.com/rsfreitas/go-rtsp/internal/rtp"
+)
+
+const (
+	// defaultMulticastPort is the RTP port of the AVP profile (RFC 3551
+	// section 11).
+	defaultMulticastPort = 5004
+	defaultMulticastTTL  = 16
+)
+
+// MulticastSetup holds the multicast group a stream is delivered to. Clients
+// asking for multicast receive the stream from the group, so each packet is
+// sent only once regardless of the number of clients. The server doesn't
+// join the group, so RTCP packets sent by clients to it are ignored: their
+// sessions are only kept alive by RTSP requests, such as GET_PARAMETER.
+type MulticastSetup struct {
+	// Group is the IPv4 multicast address, such as 239.0.0.1.
+	Group string
+
+	// PortMin and PortMax are the range of ports used by the stream tracks.
+	// Each track uses a pair of them (RTP and RTCP), starting at PortMin,
+	// which must be even. If PortMin is not set, 5004 is used, and if
+	// PortMax is not set, the range isn't limited. Streams sent to the same
+	// group can't share any port.
+	PortMin int
+	PortMax int
+
+	// TTL is the time-to-live of the multicast packets, limiting how many
+	// routers they cross. If not set, 16 is used.
+	TTL int
+}
+
+// withDefaults gives the options with the default values of the ones not
+// set.
+func (m MulticastSetup) withDefaults() MulticastSetup {
+	if m.PortMin == 0 {
+		m.PortMin = defaultMulticastPort
+	}
+
+	if m.TTL == 0 {
+		m.TTL = defaultMulticastTTL
+	}
+
+	return m
+}
+
+// validate checks if the options can be used by a stream with a number of
+// tracks.
+func (m *MulticastSetup) validate(tracks int) error {
+	ip := net.ParseIP(m.Group)
+
+	if ip == nil || ip.To4() == nil || !ip.IsMulticast() {
+		return fmt.Errorf("invalid multicast group %q", m.Group)
+	}
+
+	last := m.PortMin + 2*tracks - 1
+
+	if m.PortMin <= 0 || m.PortMin%2 != 0 || last > 65535 {
+		return fmt.Errorf("invalid multicast port %d", m.PortMin)
+	}
+
+	if m.PortMax > 0 && m.PortMax < last {
+		return fmt.Errorf("multicast ports %d-%d can't hold %d tracks", m.PortMin, m.PortMax, tracks)
+	}
+
+	if m.TTL < 1 || m.TTL > 255 {
+		return fmt.Errorf("invalid multicast TTL %d", m.TTL)
+	}
+
+	return nil
+}
+
+// multicastOverlaps tells if two streams send any of their tracks to the
+// same multicast address and port.
+func multicastOverlaps(a, b *Stream) bool {
+	ma, mb := a.setup.Multicast, b.setup.Multicast
+
+	if ma == nil || mb == nil || !net.ParseIP(ma.Group).Equal(net.ParseIP(mb.Group)) {
+		return false
+	}
+
+	lastA := ma.PortMin + 2*len(a.tracks) - 1
+	lastB := mb.PortMin + 2*len(b.tracks) - 1
+
+	return ma.PortMin <= lastB && mb.PortMin <= lastA
+}
+
+// multicastGroup delivers a track to its multicast group. A single RTP
+// session sends the track to every client which joined the group. It is
+// created when the first client joins and closed after the last one leaves,
+// and it plays while any of them is playing.
+type multicastGroup struct {
+	track   *Track
+	address string
+	port    int
+	ttl     int
+
+	lock    sync.Mutex
+	session *rtp.Session
+
+	// members tells if each client session which joined the group, keyed
+	// by its identification, is playing.
+	members map[string]bool
+}
+
+// join adds a client session to the group, giving the RTP session sending
+// the track to it. The server port of the RTP session is taken from ports.
+func (g *multicastGroup) join(id string, ports *adt.RangeBox) (*rtp.Session, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.session == nil {
+		port, err := ports.Request()
+
+		if err != nil {
+			return nil, errors.New("server doesn't have available ports to transfer")
+		}
+
+		// Tracks of the same stream share the CNAME, so clients can
+		// synchronize them.
+		session, err := rtp.NewSession(rtp.Setup{
+			ServerPort:  int(port),
+			ClientAddr:  g.address,
+			ClientPorts: []int{g.port, g.port + 1},
+			TTL:         g.ttl,
+			PayloadType: g.track.payloadType,
+			ClockRate:   g.track.clockRate,
+			CNAME:       g.track.stream.path,
+		})
+
+		if err != nil {
+			ports.Release(port)
+			return nil, err
+		}
+
+		g.session = session
+	}
+
+	if _, ok := g.members[id]; !ok {
+		g.members[id] = false
+	}
+
+	return g.session, nil
+}
+
+// leave removes a client session from the group, closing the RTP session
+// if it was the last one.
+func (g *multicastGroup) leave(id string, ports *adt.RangeBox) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if _, ok := g.members[id]; !ok {
+		return
+	}
+
+	delete(g.members, id)
+	g.update()
+
+	if len(g.members) == 0 && g.session != nil {
+		closeRTPSession(g.session, ports)
+		g.session = nil
+	}
+}
+
+// setPlaying tells if a client session of the group is playing.
+func (g *multicastGroup) setPlaying(id string, playing bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if _, ok := g.members[id]; !ok {
+		return
+	}
+
+	g.members[id] = playing
+	g.update()
+}
+
+// update plays the RTP session while any client session is playing. It
+// must be called with the lock held.
+func (g *multicastGroup) update() {
+	if g.session == nil {
+		return
+	}
+
+	for _, playing := range g.members {
+		if playing {
+			g.session.Play()
+			return
+		}
+	}
+
+	g.session.Pause()
+}
+
+// rtpSession gives the RTP session sending the track to the group, or nil
+// if no client joined it.
+func (g *multicastGroup) rtpSession() *rtp.Session {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return g.session
+}
+
+// multicastSession gives the RTP session sending the track to its multicast
+// group, or nil if the stream isn't delivered through multicast or no
+// client joined the group.
+func (t *Track) multicastSession() *rtp.Session {
+	if t.multicast == nil {
+		return nil
+	}
+
+	return t.multicast.rtpSession()
+}
+
+func newMulticastGroup(t *Track, options *MulticastSetup) *multicastGroup {
+	return &multicastGroup{
+		track:   t,
+		address: options.Group,
+		port:    options.PortMin + 2*t.index,
+		ttl:     options.TTL,
+		members: make(map[string]bool),
+	}
+}
@@ -0,0 +1,46 @@
+//
+// Description: Multicast delivery tests.
+//
+package rtsp_test
+
+import (
+	"testing"
+
+	"github.com/rsfreitas/go-rtsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddStreamMulticast(t *testing.T) {
+	assert := assert.New(t)
+	s, _, _ := startServer(t, rtsp.ServerSetup{UDPPortMin: 47900, UDPPortMax: 47999}, playHandler{})
+	defer s.Close()
+
+	multicast := func(group string, port int) *rtsp.MediaSetup {
+		return &rtsp.MediaSetup{
+			Audio:     &rtsp.AudioSetup{SampleRate: 48000, Channels: 2},
+			Multicast: &rtsp.MulticastSetup{Group: group, PortMin: port},
+		}
+	}
+
+	// Both tracks of the stream use ports 5004 to 5007
+	_, err := s.AddStream("/a", multicast("239.0.0.1", 5004))
+	assert.Nil(err)
+
+	_, err = s.AddStream("/b", multicast("239.0.0.1", 5006))
+	assert.NotNil(err)
+
+	_, err = s.AddStream("/b", multicast("239.0.0.1", 5002))
+	assert.NotNil(err)
+
+	_, err = s.AddStream("/b", multicast("239.0.0.1", 5008))
+	assert.Nil(err)
+
+	_, err = s.AddStream("/c", multicast("239.0.0.2", 5004))
+	assert.Nil(err)
+
+	// The ports are available again once the stream is removed
+	assert.Nil(s.RemoveStream("/a"))
+
+	_, err = s.AddStream("/a", multicast("239.0.0.1", 5000))
+	assert.Nil(err)
+}
@@ -22,35 +22,25 @@ func (o *optionsMethod) Verify(p *packet.Packet, handler interface{}) error {
 	return nil
 }
 
-func (o *optionsMethod) Handle(p *packet.Packet) {
+func (o *optionsMethod) Handle(p *packet.Packet, r *Request) {
 	var options strings.Builder
-	options.WriteString("OPTIONS, DESCRIBE, SETUP")
+	// PLAY, PAUSE and TEARDOWN are always handled by the server, even if
+	// the client handler doesn't implement them.
+	options.WriteString("OPTIONS, DESCRIBE, SETUP, PLAY, PAUSE, TEARDOWN")
 
-	if _, ok := o.clientHandler.(interface{ Play() }); ok {
-		options.WriteString(", PLAY")
-	}
-
-	if _, ok := o.clientHandler.(interface{ Pause() }); ok {
-		options.WriteString(", PAUSE")
-	}
-
-	if _, ok := o.clientHandler.(interface{ Teardown() }); ok {
-		options.WriteString(", TEARDOWN")
-	}
-
-	if _, ok := o.clientHandler.(interface{ Record() }); ok {
+	if _, ok := o.clientHandler.(ClientRecord); ok {
 		options.WriteString(", RECORD")
 	}
 
-	if _, ok := o.clientHandler.(interface{ Announce() }); ok {
+	if _, ok := o.clientHandler.(ClientAnnounce); ok {
 		options.WriteString(", ANNOUNCE")
 	}
 
-	if _, ok := o.clientHandler.(interface{ GetParameter() }); ok {
+	if _, ok := o.clientHandler.(ClientGetParameter); ok {
 		options.WriteString(", GET_PARAMETER")
 	}
 
-	if _, ok := o.clientHandler.(interface{ SetParameter() }); ok {
+	if _, ok := o.clientHandler.(ClientSetParameter); ok {
 		options.WriteString(", SET_PARAMETER")
 	}
 
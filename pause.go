@@ -9,17 +9,17 @@ import (
 	"net/http"
 
 	"github.com/rsfreitas/go-rtsp/internal/packet"
-	"github.com/rsfreitas/go-rtsp/internal/rtp"
 )
 
 type pauseMethod struct {
 	clientHandler ClientPause
 
-	ActiveSessions map[string]*rtp.Session
+	ActiveSessions *sessionManager
+	Streams        *streamRegistry
 }
 
 func (p *pauseMethod) Verify(pkt *packet.Packet, handler interface{}) error {
-	if m, ok := handler.(interface{ Pause() }); ok {
+	if m, ok := handler.(ClientPause); ok {
 		p.clientHandler = m
 		//	} else {
 		//		return &methodError{"client method not implemented", true}
@@ -28,36 +28,53 @@ func (p *pauseMethod) Verify(pkt *packet.Packet, handler interface{}) error {
 	return nil
 }
 
-func (p *pauseMethod) Handle(pkt *packet.Packet) {
-	var (
-		clientSession string
-		session       *rtp.Session
-	)
+func (p *pauseMethod) Handle(pkt *packet.Packet, r *Request) {
+	w := newResponse(pkt)
+	stream, control := p.Streams.lookup(pkt.Request.URL)
 
-	if p.clientHandler != nil {
-		p.clientHandler.Pause()
+	if stream == nil || !stream.hasControl(control) {
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
 
-	if field, ok := pkt.Request.Headers["Session"]; ok {
-		if p.ActiveSessions == nil {
-			pkt.Response.StatusCode = StatusAggregateOperationNotAllowed
-			pkt.Response.StatusText = StatusText(pkt.Response.StatusCode)
-			return
-		}
+	if r.Session == "" {
+		methodNotValidWithoutSession(w)
+		return
+	}
+
+	sess, ok := p.ActiveSessions.get(r.Session)
+
+	if !ok || sess.stream != stream {
+		w.WriteHeader(StatusSessionNotFound)
+		return
+	}
 
-		clientSession = field[0]
-		session, ok = p.ActiveSessions[clientSession]
+	// A single track can't be paused while the others are playing.
+	if control != "" && sess.trackCount() > 1 {
+		w.WriteHeader(StatusOnlyAggregateOperationAllowed)
+		return
+	}
+
+	if !sess.accepts(methodPause) {
+		methodNotValidInState(w, sess)
+		return
+	}
 
-		if !ok {
-			pkt.Response.StatusCode = StatusSessionNotFound
-			pkt.Response.StatusText = StatusText(pkt.Response.StatusCode)
+	if p.clientHandler != nil {
+		p.clientHandler.Pause(w, r)
+
+		if w.failed() {
 			return
 		}
 	}
 
-	session.Pause()
-	pkt.Response.StatusCode = http.StatusOK
-	pkt.Response.StatusText = http.StatusText(http.StatusOK)
+	if !sess.pause() {
+		methodNotValidInState(w, sess)
+		return
+	}
+
+	w.Header().Set("Session", sess.header())
+	w.finish()
 }
 
 func (p *pauseMethod) Type() methodType {
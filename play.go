@@ -6,30 +6,269 @@
 package rtsp
 
 import (
+	"math"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rsfreitas/go-rtsp/internal/header"
 	"github.com/rsfreitas/go-rtsp/internal/packet"
-	"github.com/rsfreitas/go-rtsp/internal/rtp"
 )
 
 type playMethod struct {
 	clientHandler ClientPlay
 
-	ActiveSessions map[string]*rtp.Session
+	ActiveSessions *sessionManager
+	Streams        *streamRegistry
 }
 
 func (p *playMethod) Verify(pkt *packet.Packet, handler interface{}) error {
-	if m, ok := handler.(interface{ Play() }); ok {
+	if m, ok := handler.(ClientPlay); ok {
 		p.clientHandler = m
-	} else {
-		return &methodError{"client method not implemented", true}
 	}
 
 	return nil
 }
 
-func (p *playMethod) Handle(pkt *packet.Packet) {
+func (p *playMethod) Handle(pkt *packet.Packet, r *Request) {
+	w := newResponse(pkt)
+	stream, control := p.Streams.lookup(pkt.Request.URL)
+
+	if stream == nil || !stream.hasControl(control) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if r.Session == "" {
+		methodNotValidWithoutSession(w)
+		return
+	}
+
+	sess, ok := p.ActiveSessions.get(r.Session)
+
+	if !ok || sess.stream != stream {
+		w.WriteHeader(StatusSessionNotFound)
+		return
+	}
+
+	// A single track can't be played while the others aren't.
+	if control != "" && sess.trackCount() > 1 {
+		w.WriteHeader(StatusOnlyAggregateOperationAllowed)
+		return
+	}
+
+	if !sess.accepts(methodPlay) {
+		methodNotValidInState(w, sess)
+		return
+	}
+
+	rng, granted, status := playRange(stream, r.Header.Get("Range"))
+
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
+	r.Range = rng
+	r.Scale, r.Speed, status = playRates(stream, r.Header)
+
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
 	if p.clientHandler != nil {
-		p.clientHandler.Play()
+		p.clientHandler.Play(w, r)
+
+		if w.failed() {
+			return
+		}
+	}
+
+	// Without a handler, there's no source to change its rate.
+	scale, scaleOK := grantedRate(w.Header(), "Scale", r.Scale, p.clientHandler != nil)
+	speed, speedOK := grantedRate(w.Header(), "Speed", r.Speed, p.clientHandler != nil)
+
+	if !scaleOK || !speedOK {
+		w.Header().Del("Scale")
+		w.Header().Del("Speed")
+		w.WriteHeader(StatusHeaderFieldNotValid)
+		return
+	}
+
+	sess.setScale(scale, rng)
+
+	// RTP-Info must be taken before playing, so no packet is sent in
+	// between.
+	rtpInfo := sess.rtpInfo(streamURL(pkt.Request.URL, control), rng)
+
+	if !sess.play() {
+		methodNotValidInState(w, sess)
+		return
+	}
+
+	// The client handler may grant a range different from the requested
+	// one, such as starting at the previous key frame.
+	if granted != "" && w.Header().Get("Range") == "" {
+		w.Header().Set("Range", granted)
+	}
+
+	if r.Scale != 0 {
+		w.Header().Set("Scale", formatRate(scale))
+	}
+
+	if r.Speed != 0 {
+		w.Header().Set("Speed", formatRate(speed))
+	}
+
+	// Set directly, since the canonical form would be Rtp-Info
+	w.Header()["RTP-Info"] = []string{rtpInfo}
+	w.Header().Set("Session", sess.header())
+	w.finish()
+}
+
+// playRates gives the Scale and Speed a PLAY request asks for, or zero if
+// they're absent, and the status code of their check. Only on-demand
+// streams support rates other than the normal one.
+func playRates(stream *Stream, h textproto.MIMEHeader) (float64, float64, int) {
+	var rates [2]float64
+
+	for i, name := range []string{"Scale", "Speed"} {
+		value := h.Get(name)
+
+		if value == "" {
+			continue
+		}
+
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+
+		if err != nil {
+			return 0, 0, http.StatusBadRequest
+		}
+
+		// Speed must be positive (RFC 2326 section 12.35), while Scale
+		// is negative when playing backwards.
+		if !validRate(rate) || (name == "Speed" && rate < 0) {
+			return 0, 0, StatusHeaderFieldNotValid
+		}
+
+		if rate != 1 && stream.setup.Duration == 0 {
+			return 0, 0, StatusHeaderFieldNotValid
+		}
+
+		rates[i] = rate
+	}
+
+	return rates[0], rates[1], http.StatusOK
+}
+
+// grantedRate gives the rate granted to a PLAY request: the one set by the
+// client handler in the response header, the requested one if the handler
+// accepted it or the normal rate. It fails if the handler set a rate which
+// can't be used.
+func grantedRate(h textproto.MIMEHeader, name string, requested float64, handled bool) (float64, bool) {
+	if value := h.Get(name); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+
+		if err != nil || !validRate(rate) {
+			return 0, false
+		}
+
+		return rate, true
+	}
+
+	if requested == 0 || !handled {
+		return 1, true
+	}
+
+	return requested, true
+}
+
+// validRate checks if a rate can be used to play a stream.
+func validRate(rate float64) bool {
+	return rate != 0 && !math.IsNaN(rate) && !math.IsInf(rate, 0)
+}
+
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+// streamURL gives the URL of the stream a request refers to, without any
+// track control.
+func streamURL(u *url.URL, control string) string {
+	s := strings.TrimSuffix(u.String(), "/")
+
+	if control != "" {
+		s = strings.TrimSuffix(s, "/"+control)
+	}
+
+	return s
+}
+
+// playRange checks the Range header of a PLAY request against the stream
+// duration. It gives the range to seek to, the Range header value granted
+// to the client and the status code of the check.
+func playRange(stream *Stream, value string) (*Range, string, int) {
+	if value == "" {
+		return nil, "", http.StatusOK
+	}
+
+	h, err := header.NewRange(value)
+
+	if err != nil {
+		return nil, "", http.StatusBadRequest
 	}
+
+	var (
+		start, end time.Duration
+		now        bool
+	)
+
+	switch {
+	case len(h.Npt) > 0:
+		now = h.Npt[0].Type == header.NptNow
+		start = h.Npt[0].Duration()
+
+		if len(h.Npt) > 1 {
+			end = h.Npt[1].Duration()
+		}
+
+	case h.Smpte != nil && len(h.Smpte.Time) > 0:
+		start = h.Smpte.Time[0].Duration(h.Smpte.Type)
+
+		if len(h.Smpte.Time) > 1 {
+			end = h.Smpte.Time[1].Duration(h.Smpte.Type)
+		}
+
+	default:
+		// Absolute (clock) ranges don't make sense for streams without a
+		// wall clock reference.
+		return nil, "", StatusHeaderFieldNotValid
+	}
+
+	duration := stream.setup.Duration
+
+	// Live streams can only be played from the current position.
+	if duration == 0 || now {
+		if start > 0 || end > 0 {
+			return nil, "", StatusInvalidRange
+		}
+
+		return nil, "npt=now-", http.StatusOK
+	}
+
+	if start > duration || end > duration || (end > 0 && end <= start) {
+		return nil, "", StatusInvalidRange
+	}
+
+	if end == 0 {
+		end = duration
+	}
+
+	return &Range{Start: start, End: end}, header.NewNptRange(start, end).String(), http.StatusOK
 }
 
 func (p *playMethod) Type() methodType {
@@ -0,0 +1,74 @@
+//
+// Description: Publisher tests.
+//
+package rtsp_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rsfreitas/go-rtsp"
+	"github.com/stretchr/testify/assert"
+)
+
+type publishHandler struct {
+	playHandler
+}
+
+func (publishHandler) Announce(w rtsp.ResponseWriter, r *rtsp.Request) {}
+func (publishHandler) Record(w rtsp.ResponseWriter, r *rtsp.Request)   {}
+
+func TestPublisher(t *testing.T) {
+	assert := assert.New(t)
+	s, _, url := startServer(t, rtsp.ServerSetup{UDPPortMin: 47300, UDPPortMax: 47399}, publishHandler{})
+	defer s.Close()
+
+	url += "/live"
+
+	p, err := rtsp.Publish(url, rtsp.PublisherSetup{
+		ClientSetup: rtsp.ClientSetup{UDPPortMin: 47400, UDPPortMax: 47499},
+		MediaSetup:  &rtsp.MediaSetup{SPS: testSPS, PPS: testPPS},
+	})
+
+	if !assert.Nil(err) {
+		return
+	}
+
+	defer p.Close()
+
+	// Only video is published
+	assert.NotNil(p.WriteAudio(0, []byte{0x21}))
+
+	frames := make(chan *rtsp.Frame, 64)
+	c, err := rtsp.Dial(url, rtsp.ClientSetup{
+		Transport: rtsp.ClientTransportTCP,
+		Receive: func(f *rtsp.Frame) {
+			select {
+			case frames <- f:
+			default:
+			}
+		},
+	})
+
+	if !assert.Nil(err) {
+		return
+	}
+
+	defer c.Close()
+
+	tracks, err := c.Describe()
+	assert.Nil(err)
+
+	if !assert.Len(tracks, 1) {
+		return
+	}
+
+	assert.Contains(tracks[0].Fmtp, "sprop-parameter-sets")
+	assert.Nil(c.Setup(tracks[0]))
+	assert.Nil(c.Play(nil))
+
+	// The server relays what the publisher writes
+	if f := receiveFrame(p.WriteVideo, frames); assert.NotNil(f) {
+		assert.True(bytes.Contains(f.Data, testFrame()))
+	}
+}
@@ -6,15 +6,22 @@
 package rtsp
 
 import (
+	"net/http"
+
 	"github.com/rsfreitas/go-rtsp/internal/packet"
 )
 
 type recordMethod struct {
 	clientHandler ClientRecord
+
+	ActiveSessions *sessionManager
+	Streams        *streamRegistry
 }
 
+// Verify requires a ClientRecord handler. Unlike playing, publishing lets
+// clients feed media to other clients, so servers must opt in to it.
 func (r *recordMethod) Verify(p *packet.Packet, handler interface{}) error {
-	if m, ok := handler.(interface{ Record() }); ok {
+	if m, ok := handler.(ClientRecord); ok {
 		r.clientHandler = m
 	} else {
 		return &methodError{"client method not implemented", true}
@@ -23,10 +30,45 @@ func (r *recordMethod) Verify(p *packet.Packet, handler interface{}) error {
 	return nil
 }
 
-func (r *recordMethod) Handle(p *packet.Packet) {
-	if r.clientHandler != nil {
-		r.clientHandler.Record()
+func (r *recordMethod) Handle(p *packet.Packet, req *Request) {
+	w := newResponse(p)
+	stream, control := r.Streams.lookup(p.Request.URL)
+
+	if stream == nil || !stream.hasControl(control) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if req.Session == "" {
+		methodNotValidWithoutSession(w)
+		return
+	}
+
+	sess, ok := r.ActiveSessions.get(req.Session)
+
+	if !ok || sess.stream != stream {
+		w.WriteHeader(StatusSessionNotFound)
+		return
 	}
+
+	if !sess.accepts(methodRecord) {
+		methodNotValidInState(w, sess)
+		return
+	}
+
+	r.clientHandler.Record(w, req)
+
+	if w.failed() {
+		return
+	}
+
+	if !sess.record() {
+		methodNotValidInState(w, sess)
+		return
+	}
+
+	w.Header().Set("Session", sess.header())
+	w.finish()
 }
 
 func (r *recordMethod) Type() methodType {
@@ -0,0 +1,118 @@
+//
+// Description: Relaying of the media received from a stream source.
+//
+package rtsp
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/rsfreitas/go-rtsp/internal/rtp"
+)
+
+// ingestClock follows the RTP timestamps of a stream source, giving the
+// time elapsed since its first packet.
+type ingestClock struct {
+	started bool
+	last    uint32
+	elapsed int64
+}
+
+// advance gives the time elapsed until rtpTime, in clock units.
+func (c *ingestClock) advance(rtpTime uint32) int64 {
+	if !c.started {
+		c.started = true
+		c.last = rtpTime
+	}
+
+	// The difference handles the timestamp wrap around
+	c.elapsed += int64(int32(rtpTime - c.last))
+	c.last = rtpTime
+
+	return c.elapsed
+}
+
+// restart makes the clock continue from its current time at the next
+// timestamp, which may belong to a new source session.
+func (c *ingestClock) restart() {
+	c.started = false
+}
+
+// relay sends a RTP packet received from the stream source, such as the
+// client publishing it, to every client playing the track. Packets are not
+// depacketized, each client receives the same payloads rewritten to its own
+// SSRC, sequence numbers and timestamps, so the media is packetized only
+// once regardless of the number of clients.
+func (t *Track) relay(p *rtp.Packet) {
+	t.ingestLock.Lock()
+	defer t.ingestLock.Unlock()
+
+	elapsed := t.ingest.advance(p.Timestamp)
+	timestamp := time.Duration(elapsed * int64(time.Second) / int64(t.clockRate))
+	atomic.StoreInt64(&t.position, int64(timestamp))
+
+	// Parameter sets not announced by the source are taken from the
+	// media, so the stream SDP can be completed.
+	if t.depacketizer != nil && !t.hasParameterSets() {
+		if sample, ok := t.depacketizer.Depacketize(p); ok {
+			t.packetizer.Packetize(sample)
+			t.updateParameterSets()
+		}
+	}
+
+	sync := t.syncPoint(p.Payload)
+
+	t.stream.lock.RLock()
+	defer t.stream.lock.RUnlock()
+
+	for _, session := range t.stream.sessions {
+		if r := session.track(t.index); r != nil {
+			r.Forward(p, uint32(elapsed), sync)
+		}
+	}
+
+	if r := t.multicastSession(); r != nil {
+		r.Forward(p, uint32(elapsed), sync)
+	}
+}
+
+// restartSource prepares the track to relay a new session of its source,
+// such as after reconnecting to it. Timestamps continue from the previous
+// session and clients wait for the next sync point.
+func (t *Track) restartSource() {
+	t.ingestLock.Lock()
+	t.ingest.restart()
+	t.ingestLock.Unlock()
+
+	t.stream.lock.RLock()
+	defer t.stream.lock.RUnlock()
+
+	for _, session := range t.stream.sessions {
+		if r := session.track(t.index); r != nil {
+			r.Resync()
+		}
+	}
+
+	if r := t.multicastSession(); r != nil {
+		r.Resync()
+	}
+}
+
+// syncPoint tells if clients may start receiving the track from a RTP
+// payload. Video starts at key frames, while any audio packet can be
+// decoded by itself.
+func (t *Track) syncPoint(payload []byte) bool {
+	if t.kind != trackVideo {
+		return true
+	}
+
+	switch t.video.Codec {
+	case VideoH263:
+		return true
+
+	case VideoH265:
+		return rtp.H265KeyFrame(payload)
+	}
+
+	return rtp.H264KeyFrame(payload)
+}
@@ -0,0 +1,103 @@
+//
+// Description: The public representation of a client request.
+//
+package rtsp
+
+import (
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rsfreitas/go-rtsp/internal/packet"
+)
+
+// Request represents a RTSP request received by the server and that is
+// passed to the client handler methods.
+type Request struct {
+	// Method is the RTSP method (PLAY, PAUSE, RECORD, etc).
+	Method string
+
+	// URL is the request URL.
+	URL *url.URL
+
+	// Version is the RTSP protocol version used by the client.
+	Version string
+
+	// Header holds all request header fields.
+	Header textproto.MIMEHeader
+
+	// Body is the request body, if any.
+	Body []byte
+
+	// Session is the session identification the request refers to. It is
+	// empty if the request doesn't carry a Session header.
+	Session string
+
+	// RemoteAddr is the network address of the client which sent the
+	// request.
+	RemoteAddr string
+
+	// Range is the part of the stream a PLAY request wants, already
+	// checked against the stream duration. It is nil if the client didn't
+	// ask to seek.
+	Range *Range
+
+	// Scale and Speed are the playback rates a PLAY request asks for, or
+	// zero if it doesn't. Scale changes the rate the stream is presented
+	// (2 for fast-forward, -1 for rewind, 0.5 for slow motion), while Speed
+	// changes the rate data is delivered. A handler that can't support a
+	// rate must reply with StatusHeaderFieldNotValid or
+	// StatusOptionNotSupported. It may also grant a different rate by
+	// setting the Scale or Speed response header.
+	Scale float64
+	Speed float64
+
+	request *packet.Request
+}
+
+// Range is a part of an on-demand stream, as offsets from its beginning.
+type Range struct {
+	Start time.Duration
+
+	// End is zero if the range lasts until the end of the stream.
+	End time.Duration
+}
+
+// Cseq gives the request sequence number.
+func (r *Request) Cseq() uint64 {
+	return r.request.Sequence()
+}
+
+func newRequest(c *conn, p *packet.Packet) *Request {
+	r := &Request{
+		Method:  p.Request.Method,
+		URL:     p.Request.URL,
+		Version: p.Request.Version,
+		Header:  textproto.MIMEHeader(p.Request.Headers),
+		Body:    p.Request.Body,
+		request: p.Request,
+	}
+
+	if c != nil {
+		r.RemoteAddr = c.RemoteAddr().String()
+	}
+
+	if r.Header == nil {
+		r.Header = make(textproto.MIMEHeader)
+	}
+
+	r.Session = sessionID(r.Header.Get("Session"))
+
+	return r
+}
+
+// sessionID extracts the session identification from a Session header
+// value, discarding its parameters (such as timeout).
+func sessionID(value string) string {
+	if i := strings.Index(value, ";"); i >= 0 {
+		value = value[:i]
+	}
+
+	return strings.TrimSpace(value)
+}
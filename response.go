@@ -0,0 +1,70 @@
+//
+// Description: The public interface to reply a client request.
+//
+package rtsp
+
+import (
+	"net/http"
+	"net/textproto"
+
+	"github.com/rsfreitas/go-rtsp/internal/packet"
+)
+
+// ResponseWriter is used by client handler methods to change the response
+// that will be sent back to the client.
+type ResponseWriter interface {
+	// Header gives the response header fields, which may be changed
+	// before the response is sent.
+	Header() textproto.MIMEHeader
+
+	// WriteHeader sets the response status code. If a status code other
+	// than a 2xx is used, the server stops handling the request and
+	// sends the response back to the client as it is.
+	WriteHeader(statusCode int)
+
+	// Write appends data to the response body.
+	Write([]byte) (int, error)
+}
+
+// response is the ResponseWriter implementation over a packet.Response.
+type response struct {
+	r *packet.Response
+}
+
+func (w *response) Header() textproto.MIMEHeader {
+	return w.r.Headers
+}
+
+func (w *response) WriteHeader(statusCode int) {
+	w.r.StatusCode = statusCode
+	w.r.StatusText = StatusText(statusCode)
+
+	if w.r.StatusText == "" {
+		w.r.StatusText = http.StatusText(statusCode)
+	}
+}
+
+func (w *response) Write(b []byte) (int, error) {
+	w.r.Body = append(w.r.Body, b...)
+
+	return len(b), nil
+}
+
+// failed tells if the client handler replied with an error.
+func (w *response) failed() bool {
+	return w.r.StatusCode >= http.StatusMultipleChoices
+}
+
+// finish sets the response as successful, if the client handler didn't
+// set a status code.
+func (w *response) finish() {
+	if w.r.StatusCode == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func newResponse(p *packet.Packet) *response {
+	return &response{
+		r: p.Response,
+	}
+}
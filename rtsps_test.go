@@ -0,0 +1,241 @@
+//
+// Description: RTSP over TLS tests.
+//
+package rtsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rsfreitas/go-rtsp"
+	"github.com/rsfreitas/go-rtsp/internal/srtp"
+	"github.com/stretchr/testify/assert"
+)
+
+// selfSignedCertificate gives a certificate for 127.0.0.1 and the pool
+// which trusts it.
+func selfSignedCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "go-rtsp"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+// request sends a RTSP request through a connection, giving the response
+// status code, headers and body.
+func request(c net.Conn, r *textproto.Reader, method, url string, cseq int, headers ...string) (int, textproto.MIMEHeader, string) {
+	fmt.Fprintf(c, "%s %s RTSP/1.0\r\nCSeq: %d\r\n", method, url, cseq)
+
+	for _, h := range headers {
+		fmt.Fprintf(c, "%s\r\n", h)
+	}
+
+	fmt.Fprint(c, "\r\n")
+
+	line, err := r.ReadLine()
+
+	if err != nil {
+		return 0, nil, ""
+	}
+
+	code, _ := strconv.Atoi(strings.Fields(line)[1])
+	header, _ := r.ReadMIMEHeader()
+	body := make([]byte, 0)
+
+	if n, err := strconv.Atoi(header.Get("Content-Length")); err == nil {
+		body = make([]byte, n)
+		io.ReadFull(r.R, body)
+	}
+
+	return code, header, string(body)
+}
+
+// mediaAttribute gives the value of the first attribute of the first media
+// of a session description.
+func mediaAttribute(sdp, name string) string {
+	media := sdp[strings.Index(sdp, "m="):]
+
+	for _, line := range strings.Split(media, "\r\n") {
+		if strings.HasPrefix(line, "a="+name+":") {
+			return strings.TrimPrefix(line, "a="+name+":")
+		}
+	}
+
+	return ""
+}
+
+func TestRTSPS(t *testing.T) {
+	assert := assert.New(t)
+	cert, pool := selfSignedCertificate(t)
+
+	s, stream, _ := startServer(t, rtsp.ServerSetup{
+		UDPPortMin:     48000,
+		UDPPortMax:     48099,
+		TLSConfig:      &tls.Config{Certificates: []tls.Certificate{cert}},
+		TLSListenAddrs: []string{"127.0.0.1:0"},
+		SRTP:           true,
+	}, playHandler{})
+
+	defer s.Close()
+
+	// TLS listeners come after the plain ones
+	addr := s.Addrs()[1].String()
+	url := "rtsps://" + addr + "/cam"
+
+	c, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: pool})
+
+	if !assert.Nil(err) {
+		return
+	}
+
+	defer c.Close()
+
+	r := textproto.NewReader(bufio.NewReader(c))
+
+	// The SRTP key of each media is announced through TLS
+	code, _, sdp := request(c, r, "DESCRIBE", url, 1, "Accept: application/sdp")
+	assert.Equal(200, code)
+	assert.Contains(sdp, "m=video 0 RTP/SAVP 96")
+
+	key, err := srtp.ParseCryptoAttribute(mediaAttribute(sdp, "crypto"))
+
+	if !assert.Nil(err) {
+		return
+	}
+
+	rtpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 48100})
+
+	if !assert.Nil(err) {
+		return
+	}
+
+	defer rtpConn.Close()
+
+	code, header, _ := request(c, r, "SETUP", url+"/"+mediaAttribute(sdp, "control"), 2,
+		"Transport: RTP/SAVP;unicast;client_port=48100-48101")
+
+	assert.Equal(200, code)
+	assert.True(strings.HasPrefix(header.Get("Transport"), "RTP/SAVP/UDP;unicast;"))
+
+	session := strings.Split(header.Get("Session"), ";")[0]
+	code, _, _ = request(c, r, "PLAY", url, 3, "Session: "+session)
+	assert.Equal(200, code)
+
+	// Media is only understood through the announced key
+	context, err := srtp.NewContext(key)
+	assert.Nil(err)
+
+	b := make([]byte, 2048)
+	n := 0
+
+	for i := 0; i < 50 && n == 0; i++ {
+		stream.WriteSample(time.Duration(i)*40*time.Millisecond, testFrame())
+		rtpConn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, _ = rtpConn.Read(b)
+	}
+
+	if assert.NotZero(n) {
+		packet, err := context.DecryptRTP(b[:n])
+
+		if assert.Nil(err) {
+			assert.Equal(byte(96), packet[1]&0x7f)
+			assert.Len(packet, n-10)
+			assert.False(bytes.Equal(b[12:n-10], packet[12:]))
+		}
+	}
+
+	code, _, _ = request(c, r, "TEARDOWN", url, 4, "Session: "+session)
+	assert.Equal(200, code)
+}
+
+func TestRTSPSClient(t *testing.T) {
+	assert := assert.New(t)
+	cert, pool := selfSignedCertificate(t)
+
+	s, stream, _ := startServer(t, rtsp.ServerSetup{
+		UDPPortMin:     48200,
+		UDPPortMax:     48299,
+		TLSConfig:      &tls.Config{Certificates: []tls.Certificate{cert}},
+		TLSListenAddrs: []string{"127.0.0.1:0"},
+		SRTP:           true,
+	}, playHandler{})
+
+	defer s.Close()
+
+	frames := make(chan *rtsp.Frame, 64)
+	c, err := rtsp.Dial("rtsps://"+s.Addrs()[1].String()+"/cam", rtsp.ClientSetup{
+		UDPPortMin: 48300,
+		UDPPortMax: 48399,
+		TLSConfig:  &tls.Config{RootCAs: pool},
+		Receive: func(f *rtsp.Frame) {
+			select {
+			case frames <- f:
+			default:
+			}
+		},
+	})
+
+	if !assert.Nil(err) {
+		return
+	}
+
+	defer c.Close()
+
+	tracks, err := c.Describe()
+
+	if !assert.Nil(err) || !assert.Len(tracks, 1) {
+		return
+	}
+
+	assert.Nil(c.Setup(tracks[0]))
+	assert.Nil(c.Play(nil))
+
+	if f := receiveFrame(stream.WriteSample, frames); assert.NotNil(f) {
+		assert.True(bytes.Contains(f.Data, testFrame()))
+	}
+}
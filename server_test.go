@@ -0,0 +1,464 @@
+//
+// Description: Server behavior tests.
+//
+package rtsp_test
+
+import (
+	"bufio"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rsfreitas/go-rtsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdleConnection(t *testing.T) {
+	assert := assert.New(t)
+	s, _, _ := startServer(t, rtsp.ServerSetup{
+		UDPPortMin:     49000,
+		UDPPortMax:     49099,
+		SessionTimeout: 200 * time.Millisecond,
+	}, playHandler{})
+
+	defer s.Close()
+
+	c, err := net.Dial("tcp", s.Addrs()[0].String())
+
+	if !assert.Nil(err) {
+		return
+	}
+
+	defer c.Close()
+
+	// Connections without requests are closed by the server
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = c.Read(make([]byte, 1))
+	assert.Equal(io.EOF, err)
+}
+
+// dialServer opens a RTSP connection to a server, giving the reader of its
+// responses.
+func dialServer(t *testing.T, s *rtsp.Server) (net.Conn, *textproto.Reader) {
+	c, err := net.Dial("tcp", s.Addrs()[0].String())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c, textproto.NewReader(bufio.NewReader(c))
+}
+
+func TestInterleavedChannels(t *testing.T) {
+	assert := assert.New(t)
+	s, _, url := startServer(t, rtsp.ServerSetup{UDPPortMin: 49100, UDPPortMax: 49199}, playHandler{})
+	defer s.Close()
+
+	for i, tc := range []struct {
+		channels string
+		code     int
+		reply    string
+	}{
+		// RTCP uses the next channel, unless there is none
+		{"interleaved=4", 200, "interleaved=4-5"},
+		{"interleaved=255", 200, "interleaved=255"},
+		{"interleaved=6-7", 200, "interleaved=6-7"},
+		{"interleaved=8-8", 461, ""},
+		{"interleaved=256", 461, ""},
+		{"interleaved=254-256", 461, ""},
+	} {
+		c, r := dialServer(t, s)
+		code, header, _ := request(c, r, "SETUP", url+"/trackID=0", i+1,
+			"Transport: RTP/AVP/TCP;unicast;"+tc.channels)
+
+		assert.Equal(tc.code, code, tc.channels)
+		assert.Contains(header.Get("Transport"), tc.reply)
+		c.Close()
+	}
+}
+
+// digestChallenge gives the nonce of a Digest challenge and if it is stale.
+func digestChallenge(header textproto.MIMEHeader) (string, bool) {
+	challenge := header.Get("WWW-Authenticate")
+	nonce := challenge[strings.Index(challenge, `nonce="`)+7:]
+
+	return nonce[:strings.Index(nonce, `"`)], strings.Contains(challenge, "stale=TRUE")
+}
+
+func TestDigestWithoutQop(t *testing.T) {
+	assert := assert.New(t)
+	s, _, url := startServer(t, rtsp.ServerSetup{
+		UDPPortMin: 49200,
+		UDPPortMax: 49299,
+		AuthType:   rtsp.AuthorizationDigest,
+		Realm:      "go-rtsp",
+		Username:   "user",
+		Password:   "secret",
+	}, playHandler{})
+
+	defer s.Close()
+
+	c, r := dialServer(t, s)
+	defer c.Close()
+
+	code, header, _ := request(c, r, "OPTIONS", url, 1)
+	assert.Equal(401, code)
+	nonce, _ := digestChallenge(header)
+
+	// RFC 2069 response, without qop, nc and cnonce
+	hash := func(s string) string {
+		return fmt.Sprintf("%x", md5.Sum([]byte(s)))
+	}
+
+	authorization := func(nonce string) string {
+		response := hash(hash("user:go-rtsp:secret") + ":" + nonce + ":" + hash("OPTIONS:"+url))
+
+		return fmt.Sprintf(`Authorization: Digest username="user", realm="go-rtsp", `+
+			`nonce="%s", uri="%s", response="%s"`, nonce, url, response)
+	}
+
+	code, _, _ = request(c, r, "OPTIONS", url, 2, authorization(nonce))
+	assert.Equal(200, code)
+
+	// The nonce can't be used again, but the client is asked to retry
+	// with a new one
+	code, header, _ = request(c, r, "OPTIONS", url, 3, authorization(nonce))
+	assert.Equal(401, code)
+	nonce, stale := digestChallenge(header)
+	assert.True(stale)
+
+	code, _, _ = request(c, r, "OPTIONS", url, 4, authorization(nonce))
+	assert.Equal(200, code)
+}
+
+// rateHandler grants a fixed Scale to every PLAY request.
+type rateHandler struct {
+	scale string
+}
+
+func (h rateHandler) Play(w rtsp.ResponseWriter, r *rtsp.Request) {
+	w.Header().Set("Scale", h.scale)
+}
+
+func TestPlayRates(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		handler interface{}
+		header  string
+		code    int
+	}{
+		{playHandler{}, "Scale: 2", 200},
+		{playHandler{}, "Scale: -1", 200},
+		{playHandler{}, "Scale: 0", 456},
+		{playHandler{}, "Scale: NaN", 456},
+		{playHandler{}, "Scale: Inf", 456},
+		{playHandler{}, "Scale: -Inf", 456},
+		{playHandler{}, "Speed: -1", 456},
+		{playHandler{}, "Speed: +Inf", 456},
+		{rateHandler{"0.5"}, "Scale: 2", 200},
+		{rateHandler{"NaN"}, "Scale: 2", 456},
+		{rateHandler{"-Inf"}, "Scale: 2", 456},
+	} {
+		s, _, _ := startServer(t, rtsp.ServerSetup{UDPPortMin: 49300, UDPPortMax: 49399}, tc.handler)
+
+		// Only on-demand streams play at other rates
+		url := "rtsp://" + s.Addrs()[0].String() + "/vod"
+		_, err := s.AddStream("/vod", &rtsp.MediaSetup{SPS: testSPS, PPS: testPPS, Duration: time.Minute})
+		assert.Nil(err)
+
+		c, r := dialServer(t, s)
+		code, header, _ := request(c, r, "SETUP", url+"/trackID=0", 1,
+			"Transport: RTP/AVP/TCP;unicast;interleaved=0-1")
+
+		if assert.Equal(200, code) {
+			session := "Session: " + strings.Split(header.Get("Session"), ";")[0]
+			code, _, _ = request(c, r, "PLAY", url, 2, session, tc.header)
+			assert.Equal(tc.code, code, tc.header)
+		}
+
+		c.Close()
+		s.Close()
+	}
+}
+
+func TestSessionStates(t *testing.T) {
+	assert := assert.New(t)
+	s, _, url := startServer(t, rtsp.ServerSetup{UDPPortMin: 49400, UDPPortMax: 49497}, playHandler{})
+	defer s.Close()
+
+	c, r := dialServer(t, s)
+	defer c.Close()
+
+	// Nothing can be played before SETUP creates a session
+	code, header, _ := request(c, r, "PLAY", url, 1)
+	assert.Equal(455, code)
+	assert.Equal("SETUP", header.Get("Allow"))
+
+	// UDP keeps RTCP out of the connection
+	code, header, _ = request(c, r, "SETUP", url+"/trackID=0", 2,
+		"Transport: RTP/AVP;unicast;client_port=49498-49499")
+
+	if !assert.Equal(200, code) {
+		return
+	}
+
+	session := "Session: " + strings.Split(header.Get("Session"), ";")[0]
+
+	for i, tc := range []struct {
+		method string
+		code   int
+		allow  string
+		state  rtsp.SessionState
+	}{
+		{"PAUSE", 455, "SETUP, PLAY, TEARDOWN", rtsp.SessionReady},
+		{"PLAY", 200, "", rtsp.SessionPlaying},
+		{"PLAY", 200, "", rtsp.SessionPlaying},
+		{"PAUSE", 200, "", rtsp.SessionReady},
+		{"PAUSE", 455, "SETUP, PLAY, TEARDOWN", rtsp.SessionReady},
+	} {
+		code, header, _ = request(c, r, tc.method, url, i+3, session)
+		assert.Equal(tc.code, code, tc.method)
+		assert.Equal(tc.allow, header.Get("Allow"), tc.method)
+
+		if sessions := s.Sessions(); assert.Len(sessions, 1) {
+			assert.Equal(tc.state, sessions[0].State, tc.method)
+		}
+	}
+
+	code, _, _ = request(c, r, "TEARDOWN", url, 10, session)
+	assert.Equal(200, code)
+
+	// The session is gone
+	code, _, _ = request(c, r, "PLAY", url, 11, session)
+	assert.Equal(454, code)
+}
+
+func TestSessionTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	// There are ports for a single session
+	s, _, url := startServer(t, rtsp.ServerSetup{
+		UDPPortMin:     49500,
+		UDPPortMax:     49501,
+		SessionTimeout: time.Second,
+	}, playHandler{})
+
+	defer s.Close()
+
+	setup := func(cseq int) (int, textproto.MIMEHeader) {
+		c, r := dialServer(t, s)
+		defer c.Close()
+
+		code, header, _ := request(c, r, "SETUP", url+"/trackID=0", cseq,
+			"Transport: RTP/AVP;unicast;client_port=49510-49511")
+
+		return code, header
+	}
+
+	code, header := setup(1)
+
+	if !assert.Equal(200, code) {
+		return
+	}
+
+	assert.True(strings.HasSuffix(header.Get("Session"), ";timeout=1"))
+	code, _ = setup(2)
+	assert.Equal(500, code)
+
+	// The client vanished, so its session is reaped and the ports can be
+	// used again
+	for i := 0; i < 30 && len(s.Sessions()) > 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	assert.Len(s.Sessions(), 0)
+	code, header = setup(3)
+	assert.Equal(200, code)
+
+	c, r := dialServer(t, s)
+	defer c.Close()
+
+	code, _, _ = request(c, r, "TEARDOWN", url, 4, "Session: "+strings.Split(header.Get("Session"), ";")[0])
+	assert.Equal(200, code)
+}
+
+func TestConcurrentSessions(t *testing.T) {
+	assert := assert.New(t)
+	s, _, url := startServer(t, rtsp.ServerSetup{UDPPortMin: 49600, UDPPortMax: 49699}, playHandler{})
+	defer s.Close()
+
+	var wg sync.WaitGroup
+	ids := make(chan string)
+
+	for i := 0; i < 10; i++ {
+		c, r := dialServer(t, s)
+		defer c.Close()
+		wg.Add(1)
+
+		go func(c net.Conn, r *textproto.Reader) {
+			defer wg.Done()
+
+			for cseq := 1; cseq <= 20; cseq += 2 {
+				code, header, _ := request(c, r, "SETUP", url+"/trackID=0", cseq,
+					"Transport: RTP/AVP;unicast;client_port=49700-49701")
+
+				if !assert.Equal(200, code) {
+					return
+				}
+
+				id := strings.Split(header.Get("Session"), ";")[0]
+				ids <- id
+				code, _, _ = request(c, r, "TEARDOWN", url, cseq+1, "Session: "+id)
+				assert.Equal(200, code)
+			}
+		}(c, r)
+	}
+
+	go func() {
+		wg.Wait()
+		close(ids)
+	}()
+
+	// Sessions are inspected while they're created and closed, so they
+	// may be gone already
+	for id := range ids {
+		if info, ok := s.Session(id); ok {
+			assert.Equal("/cam", info.Path)
+		}
+
+		s.Sessions()
+		s.StreamSessions("/cam")
+	}
+
+	assert.Len(s.Sessions(), 0)
+}
+
+func TestStreamPaths(t *testing.T) {
+	assert := assert.New(t)
+	s, _, url := startServer(t, rtsp.ServerSetup{UDPPortMin: 49800, UDPPortMax: 49899}, playHandler{})
+	defer s.Close()
+
+	base := "rtsp://" + s.Addrs()[0].String()
+	_, err := s.AddStream("/cam2/sub", &rtsp.MediaSetup{SPS: testSPS, PPS: testPPS})
+	assert.Nil(err)
+
+	// Paths can't be used twice
+	_, err = s.AddStream("/cam2/sub", &rtsp.MediaSetup{SPS: testSPS, PPS: testPPS})
+	assert.NotNil(err)
+
+	c, r := dialServer(t, s)
+	defer c.Close()
+
+	for i, tc := range []struct {
+		method string
+		url    string
+		code   int
+	}{
+		{"DESCRIBE", url, 200},
+		{"DESCRIBE", base + "/cam2/sub", 200},
+		{"DESCRIBE", base + "/cam2", 404},
+		{"DESCRIBE", base + "/unknown", 404},
+		{"SETUP", base + "/unknown/trackID=0", 404},
+		{"SETUP", url + "/trackID=1", 404},
+		{"PLAY", base + "/unknown", 404},
+		{"PAUSE", url + "/trackID=1", 404},
+		{"TEARDOWN", base + "/unknown", 404},
+	} {
+		code, _, _ := request(c, r, tc.method, tc.url, i+1,
+			"Transport: RTP/AVP;unicast;client_port=49900-49901", "Session: 1234")
+
+		assert.Equal(tc.code, code, tc.method+" "+tc.url)
+	}
+
+	// Removed streams can't be found anymore
+	assert.Nil(s.RemoveStream("/cam2/sub"))
+	assert.NotNil(s.RemoveStream("/cam2/sub"))
+
+	code, _, _ := request(c, r, "DESCRIBE", base+"/cam2/sub", 20)
+	assert.Equal(404, code)
+}
+
+func TestListenAddrs(t *testing.T) {
+	assert := assert.New(t)
+	addrs := []string{"127.0.0.1:0"}
+
+	// IPv6 is only used where available
+	if l, err := net.Listen("tcp", "[::1]:0"); err == nil {
+		l.Close()
+		addrs = append(addrs, "[::1]:0")
+	}
+
+	// Listeners are open before the server is created
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if !assert.Nil(err) {
+		return
+	}
+
+	s, err := rtsp.NewServer(rtsp.ServerSetup{
+		Listeners:   []net.Listener{l},
+		ListenAddrs: addrs,
+		UDPPortMin:  49900,
+		UDPPortMax:  49979,
+		MediaSetup:  &rtsp.MediaSetup{SPS: testSPS, PPS: testPPS},
+	}, playHandler{})
+
+	if !assert.Nil(err) {
+		l.Close()
+		return
+	}
+
+	go s.Start()
+	defer s.Close()
+
+	if !assert.Len(s.Addrs(), len(addrs)+1) {
+		return
+	}
+
+	assert.Equal(l.Addr(), s.Addrs()[0])
+
+	for i, addr := range s.Addrs() {
+		host, _, _ := net.SplitHostPort(addr.String())
+		family := "IP4"
+
+		if strings.Contains(host, ":") {
+			family = "IP6"
+		}
+
+		c, err := net.Dial("tcp", addr.String())
+
+		if !assert.Nil(err) {
+			continue
+		}
+
+		r := textproto.NewReader(bufio.NewReader(c))
+		url := "rtsp://" + addr.String() + "/"
+
+		// The session description and the transport use the address the
+		// client connected to
+		code, _, sdp := request(c, r, "DESCRIBE", url, 1, "Accept: application/sdp")
+		assert.Equal(200, code, addr.String())
+		assert.Contains(sdp, "IN "+family+" "+host, addr.String())
+
+		code, header, _ := request(c, r, "SETUP", url+"trackID=0", 2,
+			fmt.Sprintf("Transport: RTP/AVP;unicast;client_port=%d-%d", 49990+i*2, 49991+i*2))
+
+		if assert.Equal(200, code, addr.String()) {
+			assert.Contains(header.Get("Transport"), "source="+host, addr.String())
+
+			code, _, _ = request(c, r, "TEARDOWN", url, 3,
+				"Session: "+strings.Split(header.Get("Session"), ";")[0])
+
+			assert.Equal(200, code)
+		}
+
+		c.Close()
+	}
+}
@@ -0,0 +1,692 @@
+//
+// Description: Client sessions.
+//
+package rtsp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rsfreitas/go-rtsp/internal/adt"
+	"github.com/rsfreitas/go-rtsp/internal/header"
+	"github.com/rsfreitas/go-rtsp/internal/rtp"
+)
+
+// TrackStatistics holds the RTP statistics of a session track, with the
+// data sent to the client and the reception quality it reported through
+// RTCP.
+type TrackStatistics struct {
+	// Control identifies the stream track.
+	Control string
+	SSRC    uint32
+
+	// Transport is the Transport header agreed with the client.
+	Transport string
+
+	PacketsSent uint64
+	OctetsSent  uint64
+
+	// PacketsLost is the cumulative number of packets lost and FractionLost
+	// is the fraction lost since the previous report, from 0 to 1.
+	PacketsLost  int
+	FractionLost float64
+	Jitter       time.Duration
+
+	// RTT is the round-trip time between server and client, known after the
+	// client reports the reception of a sender report.
+	RTT time.Duration
+
+	// LastReport is the time of the last client report, or zero if none was
+	// received.
+	LastReport time.Time
+}
+
+// SessionState is the state of a client session.
+type SessionState int
+
+const (
+	// SessionInit sessions were created, but don't have any track setup
+	// yet.
+	SessionInit SessionState = iota + 1
+
+	// SessionReady sessions have their tracks setup, but aren't playing.
+	SessionReady
+
+	// SessionPlaying sessions are sending data to their clients.
+	SessionPlaying
+
+	// SessionRecording sessions are receiving data from their clients.
+	SessionRecording
+)
+
+func (s SessionState) String() string {
+	switch s {
+	case SessionInit:
+		return "Init"
+
+	case SessionReady:
+		return "Ready"
+
+	case SessionPlaying:
+		return "Playing"
+
+	case SessionRecording:
+		return "Recording"
+	}
+
+	return "Unknown"
+}
+
+// stateTransition is a method accepted in a session state and the state
+// the session moves to after it.
+type stateTransition struct {
+	method methodType
+	next   SessionState
+}
+
+// sessionTransitions is the server state machine (RFC 2326 appendix A.2).
+// Methods not listed for a state are not valid in it.
+var sessionTransitions = map[SessionState][]stateTransition{
+	SessionInit: {
+		{methodSetup, SessionReady},
+		{methodTeardown, SessionInit},
+	},
+	SessionReady: {
+		{methodSetup, SessionReady},
+		{methodPlay, SessionPlaying},
+		{methodRecord, SessionRecording},
+		{methodTeardown, SessionInit},
+	},
+	SessionPlaying: {
+		{methodSetup, SessionPlaying},
+		{methodPlay, SessionPlaying},
+		{methodPause, SessionReady},
+		{methodTeardown, SessionInit},
+	},
+	SessionRecording: {
+		{methodSetup, SessionRecording},
+		{methodRecord, SessionRecording},
+		{methodPause, SessionReady},
+		{methodTeardown, SessionInit},
+	},
+}
+
+// SessionInfo describes a client session.
+type SessionInfo struct {
+	ID string
+
+	// Path is the URL path of the stream used by the session.
+	Path string
+
+	// RemoteAddr is the network address of the client which created the
+	// session.
+	RemoteAddr string
+
+	State        SessionState
+	Created      time.Time
+	LastActivity time.Time
+
+	// PacketsSent and OctetsSent are the totals of all session tracks.
+	PacketsSent uint64
+	OctetsSent  uint64
+
+	Tracks []TrackStatistics
+}
+
+// session is a client RTSP session. It holds the RTP sessions of every
+// stream track the client has setup, keyed by the track index, or the
+// multicast groups of the tracks it receives through multicast. A session
+// expires if the client doesn't send a request or, for unicast tracks, a
+// RTCP packet within its timeout.
+type session struct {
+	id         string
+	stream     *Stream
+	timeout    time.Duration
+	remoteAddr string
+	created    time.Time
+
+	lock         sync.RWMutex
+	tracks       map[int]*rtp.Session
+	multicast    map[int]*multicastGroup
+	transports   map[int]string
+	conn         *conn
+	lastActivity time.Time
+	state        SessionState
+
+	// publishing sessions receive the stream media from their clients,
+	// instead of sending it.
+	publishing bool
+}
+
+// setupTrack attaches the RTP session of a track, with the transport agreed
+// with the client. Interleaved sessions are also registered in the
+// connection carrying their data.
+func (s *session) setupTrack(index int, r *rtp.Session, transport string, c *conn) {
+	s.lock.Lock()
+	s.tracks[index] = r
+	s.transports[index] = transport
+
+	if r.IsInterleaved() {
+		s.conn = c
+	}
+
+	s.next(methodSetup)
+	playing := s.state == SessionPlaying
+	s.lock.Unlock()
+
+	if r.IsInterleaved() {
+		c.addTrack(s, r)
+	}
+
+	// Tracks added to a session already playing start right away.
+	if playing {
+		r.Play()
+	}
+}
+
+// joinGroup attaches a track received from its multicast group, with the
+// transport agreed with the client.
+func (s *session) joinGroup(index int, g *multicastGroup, transport string) {
+	s.lock.Lock()
+	s.multicast[index] = g
+	s.transports[index] = transport
+	s.next(methodSetup)
+	playing := s.state == SessionPlaying
+	s.lock.Unlock()
+
+	if playing {
+		g.setPlaying(s.id, true)
+	}
+}
+
+// touch keeps the session alive.
+func (s *session) touch() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.lastActivity = time.Now()
+}
+
+// expired tells if the client has not been active within the session
+// timeout.
+func (s *session) expired(now time.Time) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return now.Sub(s.lastActivity) > s.timeout
+}
+
+// header gives the Session header value, advertising the session timeout
+// to the client.
+func (s *session) header() string {
+	return fmt.Sprintf("%s;timeout=%d", s.id, int(s.timeout/time.Second))
+}
+
+// track gives the RTP session of a track, or nil if the track was not
+// setup or it is received through multicast.
+func (s *session) track(index int) *rtp.Session {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.tracks[index]
+}
+
+// group gives the multicast group a track is received from, or nil if the
+// track was not setup or it is unicast.
+func (s *session) group(index int) *multicastGroup {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.multicast[index]
+}
+
+// hasTrack tells if a track was setup, either unicast or multicast.
+func (s *session) hasTrack(index int) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, unicast := s.tracks[index]
+	_, multicast := s.multicast[index]
+
+	return unicast || multicast
+}
+
+// rtpSession gives the RTP session sending a track to the client, which is
+// shared with other clients for multicast tracks, or nil if the track was
+// not setup. It must be called with the session lock held.
+func (s *session) rtpSession(index int) *rtp.Session {
+	if g, ok := s.multicast[index]; ok {
+		return g.rtpSession()
+	}
+
+	return s.tracks[index]
+}
+
+// rtpSessions gives the RTP sessions of all tracks.
+func (s *session) rtpSessions() []*rtp.Session {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var sessions []*rtp.Session
+
+	for _, r := range s.tracks {
+		sessions = append(sessions, r)
+	}
+
+	return sessions
+}
+
+// groups gives the multicast groups of all tracks received through
+// multicast.
+func (s *session) groups() []*multicastGroup {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var groups []*multicastGroup
+
+	for _, g := range s.multicast {
+		groups = append(groups, g)
+	}
+
+	return groups
+}
+
+// trackCount gives the number of tracks setup.
+func (s *session) trackCount() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return len(s.tracks) + len(s.multicast)
+}
+
+// statistics gives the RTP statistics of all tracks, ordered as they're
+// found inside the stream.
+func (s *session) statistics() []TrackStatistics {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var stats []TrackStatistics
+
+	for _, t := range s.stream.tracks {
+		r := s.rtpSession(t.index)
+
+		if r == nil {
+			continue
+		}
+
+		rs := r.Statistics()
+		stats = append(stats, TrackStatistics{
+			Control:      t.control,
+			SSRC:         r.SSRC(),
+			Transport:    s.transports[t.index],
+			PacketsSent:  rs.PacketsSent,
+			OctetsSent:   rs.OctetsSent,
+			PacketsLost:  rs.PacketsLost,
+			FractionLost: rs.FractionLost,
+			Jitter:       rs.Jitter,
+			RTT:          rs.RTT,
+			LastReport:   rs.LastReport,
+		})
+	}
+
+	return stats
+}
+
+// info gives the public description of the session.
+func (s *session) info() SessionInfo {
+	tracks := s.statistics()
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	info := SessionInfo{
+		ID:           s.id,
+		Path:         s.stream.path,
+		RemoteAddr:   s.remoteAddr,
+		State:        s.state,
+		Created:      s.created,
+		LastActivity: s.lastActivity,
+		Tracks:       tracks,
+	}
+
+	for _, t := range tracks {
+		info.PacketsSent += t.PacketsSent
+		info.OctetsSent += t.OctetsSent
+	}
+
+	return info
+}
+
+// currentState gives the session state.
+func (s *session) currentState() SessionState {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.state
+}
+
+// rtpInfo gives the RTP-Info header value of the session tracks, telling
+// the client how the next RTP packets map to the stream position. The base
+// is the stream URL and rng the range being played, if the client asked for
+// one. Otherwise, playback continues from the last sample written.
+func (s *session) rtpInfo(base string, rng *Range) string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	info := header.NewRTPInfo()
+
+	for _, t := range s.stream.tracks {
+		r := s.rtpSession(t.index)
+
+		if r == nil {
+			continue
+		}
+
+		position := t.currentPosition()
+
+		if rng != nil {
+			position = rng.Start
+		}
+
+		sequence, rtpTime := r.Next(t.rtpTime(position))
+		info.Append(base+"/"+t.control, sequence, rtpTime)
+	}
+
+	return info.String()
+}
+
+// setScale changes the rate the session tracks are presented at, starting
+// from the stream position being played. Multicast tracks are shared with
+// other clients, so they keep their rate.
+func (s *session) setScale(scale float64, rng *Range) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for _, t := range s.stream.tracks {
+		r, ok := s.tracks[t.index]
+
+		if !ok {
+			continue
+		}
+
+		position := t.currentPosition()
+
+		if rng != nil {
+			position = rng.Start
+		}
+
+		r.SetScale(scale, t.rtpTime(position))
+	}
+}
+
+// accepts tells if a method is valid in the current session state.
+func (s *session) accepts(method methodType) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, ok := s.transition(method)
+
+	return ok
+}
+
+// allow gives the methods valid in the current session state, as used by
+// the Allow header.
+func (s *session) allow() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var methods []string
+
+	for _, t := range sessionTransitions[s.state] {
+		if s.supports(t.method) {
+			methods = append(methods, t.method.String())
+		}
+	}
+
+	return strings.Join(methods, ", ")
+}
+
+// transition gives the state the session moves to after a method, and if
+// the method is valid in the current state. It must be called with the
+// session lock held.
+func (s *session) transition(method methodType) (SessionState, bool) {
+	for _, t := range sessionTransitions[s.state] {
+		if t.method == method && s.supports(method) {
+			return t.next, true
+		}
+	}
+
+	return s.state, false
+}
+
+// supports tells if a method may be used with the session: sessions
+// publishing a stream can't play it, while the others can't record.
+func (s *session) supports(method methodType) bool {
+	switch method {
+	case methodPlay:
+		return !s.publishing
+
+	case methodRecord:
+		return s.publishing
+	}
+
+	return true
+}
+
+// next moves the session to the state reached by a method. It must be
+// called with the session lock held.
+func (s *session) next(method methodType) bool {
+	state, ok := s.transition(method)
+
+	if ok {
+		s.state = state
+	}
+
+	return ok
+}
+
+// play starts sending data to the client. It fails if the session state
+// doesn't allow it.
+func (s *session) play() bool {
+	s.lock.Lock()
+	ok := s.next(methodPlay)
+	s.lock.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	for _, r := range s.rtpSessions() {
+		r.Play()
+	}
+
+	for _, g := range s.groups() {
+		g.setPlaying(s.id, true)
+	}
+
+	return true
+}
+
+// pause stops sending data to the client, keeping its resources. It fails
+// if the session state doesn't allow it.
+func (s *session) pause() bool {
+	s.lock.Lock()
+	ok := s.next(methodPause)
+	s.lock.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	for _, r := range s.rtpSessions() {
+		r.Pause()
+	}
+
+	for _, g := range s.groups() {
+		g.setPlaying(s.id, false)
+	}
+
+	return true
+}
+
+// record starts receiving data from the client. It fails if the session
+// state doesn't allow it.
+func (s *session) record() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.next(methodRecord)
+}
+
+// closeTrack closes the RTP session of a track, releasing its server ports,
+// or leaves its multicast group. It tells if the track was setup.
+func (s *session) closeTrack(index int, ports *adt.RangeBox) bool {
+	s.lock.Lock()
+	r, ok := s.tracks[index]
+	g, multicast := s.multicast[index]
+	delete(s.tracks, index)
+	delete(s.multicast, index)
+	delete(s.transports, index)
+	c := s.conn
+	s.lock.Unlock()
+
+	if multicast {
+		g.leave(s.id, ports)
+		return true
+	}
+
+	if !ok {
+		return false
+	}
+
+	if r.IsInterleaved() && c != nil {
+		c.removeTrack(r)
+	}
+
+	closeRTPSession(r, ports)
+
+	return true
+}
+
+// close closes the RTP sessions of all tracks, releasing their server
+// ports, and leaves the multicast groups.
+func (s *session) close(ports *adt.RangeBox) {
+	s.lock.RLock()
+	c := s.conn
+	s.lock.RUnlock()
+
+	if c != nil {
+		c.removeSession(s)
+	}
+
+	s.lock.Lock()
+	tracks := s.tracks
+	groups := s.multicast
+	s.tracks = make(map[int]*rtp.Session)
+	s.multicast = make(map[int]*multicastGroup)
+	s.lock.Unlock()
+
+	for _, r := range tracks {
+		closeRTPSession(r, ports)
+	}
+
+	for _, g := range groups {
+		g.leave(s.id, ports)
+	}
+}
+
+func closeRTPSession(r *rtp.Session, ports *adt.RangeBox) {
+	if !r.IsInterleaved() {
+		ports.Release(uint32(r.Port()))
+	}
+
+	r.Close()
+}
+
+func newSession(id string, stream *Stream, timeout time.Duration, remoteAddr string, publishing bool) *session {
+	now := time.Now()
+
+	return &session{
+		id:           id,
+		stream:       stream,
+		timeout:      timeout,
+		remoteAddr:   remoteAddr,
+		created:      now,
+		tracks:       make(map[int]*rtp.Session),
+		multicast:    make(map[int]*multicastGroup),
+		transports:   make(map[int]string),
+		lastActivity: now,
+		state:        SessionInit,
+		publishing:   publishing,
+	}
+}
+
+// methodNotValidInState replies that a method can't be used in the current
+// session state, telling the client which ones it can use.
+func methodNotValidInState(w *response, sess *session) {
+	w.Header().Set("Allow", sess.allow())
+	w.WriteHeader(StatusMethodNotValidInThisState)
+}
+
+// methodNotValidWithoutSession replies to a request which needs a session,
+// sent without one. Clients are in the Init state, where only SETUP creates
+// a session.
+func methodNotValidWithoutSession(w *response) {
+	w.Header().Set("Allow", methodSetup.String())
+	w.WriteHeader(StatusMethodNotValidInThisState)
+}
+
+// sessionManager holds all client sessions of the server, keyed by their
+// identification.
+type sessionManager struct {
+	lock     sync.RWMutex
+	sessions map[string]*session
+}
+
+// add registers a new session.
+func (m *sessionManager) add(sess *session) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.sessions[sess.id] = sess
+}
+
+// remove unregisters a session.
+func (m *sessionManager) remove(id string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.sessions, id)
+}
+
+// get gives a session from its identification.
+func (m *sessionManager) get(id string) (*session, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	sess, ok := m.sessions[id]
+
+	return sess, ok
+}
+
+// list gives all sessions.
+func (m *sessionManager) list() []*session {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	sessions := make([]*session, 0, len(m.sessions))
+
+	for _, sess := range m.sessions {
+		sessions = append(sessions, sess)
+	}
+
+	return sessions
+}
+
+func newSessionManager() *sessionManager {
+	return &sessionManager{
+		sessions: make(map[string]*session),
+	}
+}
@@ -14,7 +14,7 @@ type setParameterMethod struct {
 }
 
 func (s *setParameterMethod) Verify(p *packet.Packet, handler interface{}) error {
-	if m, ok := handler.(interface{ SetParameter() }); ok {
+	if m, ok := handler.(ClientSetParameter); ok {
 		s.clientHandler = m
 	} else {
 		return &methodError{"client method not implemented", true}
@@ -23,10 +23,14 @@ func (s *setParameterMethod) Verify(p *packet.Packet, handler interface{}) error
 	return nil
 }
 
-func (s *setParameterMethod) Handle(p *packet.Packet) {
+func (s *setParameterMethod) Handle(p *packet.Packet, r *Request) {
+	w := newResponse(p)
+
 	if s.clientHandler != nil {
-		s.clientHandler.SetParameter()
+		s.clientHandler.SetParameter(w, r)
 	}
+
+	w.finish()
 }
 
 func (s *setParameterMethod) Type() methodType {
@@ -6,47 +6,87 @@
 package rtsp
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gofrs/uuid"
+	"github.com/rsfreitas/go-rtsp/internal/adt"
 	"github.com/rsfreitas/go-rtsp/internal/header"
 	"github.com/rsfreitas/go-rtsp/internal/packet"
 	"github.com/rsfreitas/go-rtsp/internal/rtp"
 )
 
 type setupMethod struct {
-	ActiveSessions map[string]*rtp.Session
+	ActiveSessions *sessionManager
+	AvailablePorts *adt.RangeBox
+	Streams        *streamRegistry
+	Conn           *conn
+	SessionTimeout time.Duration
 	ServerPortMin  int
 	ServerPortMax  int
+
+	// PublicAddr, if set, is advertised as the media source instead of
+	// the address the client connected to, and AllowDestination lets the
+	// client choose where unicast media is sent.
+	PublicAddr       string
+	AllowDestination bool
+
+	// SRTP requires clients connected through TLS to receive UDP media
+	// protected by the SRTP keys announced to them.
+	SRTP bool
 }
 
 func (s *setupMethod) Verify(p *packet.Packet, handler interface{}) error {
 	return nil
 }
 
-func (s *setupMethod) Handle(p *packet.Packet) {
+func (s *setupMethod) Handle(p *packet.Packet, r *Request) {
 	var (
-		transport     *header.Transport
-		clientSession string
-		session       *rtp.Session
-		err           error
+		transport *header.Transport
+		sess      *session
+		err       error
 	)
 
+	stream, control := s.Streams.lookup(p.Request.URL)
+
+	if stream == nil || !stream.hasControl(control) {
+		p.Response.StatusCode = http.StatusNotFound
+		p.Response.StatusText = http.StatusText(http.StatusNotFound)
+		return
+	}
+
+	// Streams with more than one track must have each of them setup
+	// separately.
+	track, ok := stream.track(control)
+
+	if !ok {
+		p.Response.StatusCode = StatusAggregateOperationNotAllowed
+		p.Response.StatusText = StatusText(p.Response.StatusCode)
+		return
+	}
+
 	// We look for a Session identification inside the Request Headers so
-	// we can change it's parameters.
-	if field, ok := p.Request.Headers["Session"]; ok {
-		if s.ActiveSessions == nil {
-			p.Response.StatusCode = StatusAggregateOperationNotAllowed
+	// we can add another track to it.
+	if _, ok := p.Request.Headers["Session"]; ok {
+		sess, ok = s.ActiveSessions.get(r.Session)
+
+		if !ok || sess.stream != stream {
+			p.Response.StatusCode = StatusSessionNotFound
 			p.Response.StatusText = StatusText(p.Response.StatusCode)
 			return
 		}
 
-		clientSession = field[0]
-		session, ok = s.ActiveSessions[clientSession]
+		if !sess.accepts(methodSetup) {
+			methodNotValidInState(newResponse(p), sess)
+			return
+		}
 
-		if !ok {
-			p.Response.StatusCode = StatusSessionNotFound
-			p.Response.StatusText = StatusText(p.Response.StatusCode)
+		// A track already setup may have its transport changed, but only
+		// while it isn't sending data.
+		if sess.hasTrack(track.index) && sess.currentState() != SessionReady {
+			methodNotValidInState(newResponse(p), sess)
 			return
 		}
 	}
@@ -67,77 +107,328 @@ func (s *setupMethod) Handle(p *packet.Packet) {
 		}
 	}
 
-	if session == nil {
-		// Creates a new RTP session to transfer data to client.
-		u, err := uuid.NewV4()
+	// Only the client which announced a stream may publish it, and it
+	// can't play the stream within the same session.
+	record := transport.IsRecord()
 
-		if err != nil {
-			p.Response.StatusCode = http.StatusInternalServerError
-			p.Response.StatusText = "Unable to create new session"
+	if record && (stream.publisher == nil || stream.publisher != s.Conn) {
+		p.Response.StatusCode = http.StatusForbidden
+		p.Response.StatusText = http.StatusText(p.Response.StatusCode)
+		return
+	}
+
+	if sess != nil && sess.publishing != record {
+		p.Response.StatusCode = StatusUnsupportedTransport
+		p.Response.StatusText = StatusText(p.Response.StatusCode)
+		return
+	}
+
+	// SRTP (RTP/SAVP) uses the key announced to the client when the stream
+	// was described, so it is only available for unicast tracks played by
+	// TLS clients. UDP media of those clients must be protected by it.
+	var key []byte
+
+	if transport.IsSecure() {
+		var found bool
+
+		key, found = s.Conn.trackKey(track)
+
+		if !found || record || transport.Delivery == header.TransportMulticast {
+			p.Response.StatusCode = StatusUnsupportedTransport
+			p.Response.StatusText = StatusText(p.Response.StatusCode)
 			return
 		}
+	} else if s.SRTP && s.Conn.secure() && transport.LowerTransport != "TCP" {
+		p.Response.StatusCode = StatusUnsupportedTransport
+		p.Response.StatusText = StatusText(p.Response.StatusCode)
+		return
+	}
+
+	// The previous transport of the track, if any, is kept until the new
+	// one is created, so it still works if this request fails.
+	var replaced *rtp.Session
 
-		var options rtp.Setup
+	if sess != nil {
+		replaced = sess.track(track.index)
+	}
+
+	// Creates a new RTP session to transfer the track data to client, or
+	// joins the client to the track multicast group.
+	var (
+		options rtp.Setup
+		group   *multicastGroup
+	)
 
-		switch transport.LowerTransport {
-		case "TCP":
+	switch {
+	case transport.Delivery == header.TransportMulticast:
+		if track.multicast == nil || record {
 			p.Response.StatusCode = StatusUnsupportedTransport
 			p.Response.StatusText = StatusText(p.Response.StatusCode)
 			return
+		}
 
-		default:
-			if !transport.HasParameter("client_port") {
-				p.Response.StatusCode = StatusParameterNotUnderstood
-				p.Response.StatusText = StatusText(p.Response.StatusCode)
-				return
-			}
+		group = track.multicast
 
-			options = rtp.Setup{
-				ServerPort:  s.ServerPortMin,
-				ServerAddr:  "127.0.0.1",
-				ClientAddr:  "127.0.0.1", // FIXME
-				ClientPorts: transport.ClientPort,
-			}
+	case transport.LowerTransport == "TCP":
+		if len(transport.Interleaved) == 0 {
+			transport.Interleaved = s.nextInterleavedChannels(replaced)
+		} else if ch := transport.Interleaved[0]; len(transport.Interleaved) == 1 && ch >= 0 && ch < 255 {
+			// RTCP is sent through the next channel
+			transport.Interleaved = []int{ch, ch + 1}
+		}
+
+		if !validChannels(transport.Interleaved) || !s.Conn.channelsAvailable(transport.Interleaved, replaced) {
+			p.Response.StatusCode = StatusUnsupportedTransport
+			p.Response.StatusText = StatusText(p.Response.StatusCode)
+			return
+		}
+
+		options = rtp.Setup{
+			Interleaved: transport.Interleaved,
+			Writer:      s.Conn,
+		}
+
+	default:
+		if !transport.HasParameter("client_port") {
+			p.Response.StatusCode = StatusParameterNotUnderstood
+			p.Response.StatusText = StatusText(p.Response.StatusCode)
+			return
+		}
+
+		clientAddr, ok := s.destination(transport)
+
+		if !ok {
+			p.Response.StatusCode = http.StatusForbidden
+			p.Response.StatusText = http.StatusText(p.Response.StatusCode)
+			return
 		}
 
-		s.ActiveSessions[u.String()] = rtp.NewSession(options)
-		p.Response.Headers.Add("Session", u.String())
+		port, err := s.AvailablePorts.Request()
+
+		if err != nil {
+			p.Response.StatusCode = http.StatusInternalServerError
+			p.Response.StatusText = "server doesn't have available ports to transfer"
+			return
+		}
+
+		// Media is sent from the address the request came in on, so it
+		// uses the same interface and address family.
+		s.ServerPortMin = int(port)
+		s.ServerPortMax = int(port + 1)
+
+		options = rtp.Setup{
+			ServerPort:  s.ServerPortMin,
+			ServerAddr:  hostAddr(s.Conn.LocalAddr()),
+			ClientAddr:  clientAddr,
+			ClientPorts: transport.ClientPort,
+		}
+	}
+
+	options.PayloadType = track.payloadType
+	options.ClockRate = track.clockRate
+	options.SRTPKey = key
+
+	if sess == nil {
+		u, err := uuid.NewV4()
+
+		if err != nil {
+			s.releasePort(options)
+			p.Response.StatusCode = http.StatusInternalServerError
+			p.Response.StatusText = "Unable to create new session"
+			return
+		}
+
+		sess = newSession(u.String(), stream, s.SessionTimeout, r.RemoteAddr, record)
+	}
+
+	// All tracks from the session share the same CNAME, so clients can
+	// synchronize them.
+	options.CNAME = sess.id
+	options.Activity = sess.touch
+
+	if record {
+		options.Receive = track.relay
+	}
+
+	var session *rtp.Session
+
+	if group != nil {
+		session, err = group.join(sess.id, s.AvailablePorts)
 	} else {
-		// We still don't handle a session update coming from the client
-		// side. So we return an error for that.
-		p.Response.StatusCode = StatusAggregateOperationNotAllowed
-		p.Response.StatusText = StatusText(p.Response.StatusCode)
+		session, err = rtp.NewSession(options)
+	}
+
+	if err != nil {
+		s.releasePort(options)
+		p.Response.StatusCode = http.StatusInternalServerError
+		p.Response.StatusText = "Unable to create RTP session"
 		return
 	}
 
-	p.Response.Headers.Add("Transport", s.transportHeader(p, transport))
+	if _, ok := s.ActiveSessions.get(sess.id); !ok {
+		s.ActiveSessions.add(sess)
+		stream.addSession(sess)
+	}
+
+	reply := s.transportHeader(p, transport, session, group)
+
+	// A client already in the track group just keeps its membership
+	if sess.hasTrack(track.index) && (group == nil || sess.group(track.index) != group) {
+		sess.closeTrack(track.index, s.AvailablePorts)
+	}
+
+	if group != nil {
+		sess.joinGroup(track.index, group, reply)
+	} else {
+		sess.setupTrack(track.index, session, reply, s.Conn)
+	}
+
+	p.Response.Headers.Add("Session", sess.header())
+	p.Response.Headers.Add("Transport", reply)
 	p.Response.StatusCode = http.StatusOK
 	p.Response.StatusText = http.StatusText(http.StatusOK)
 }
 
-func (s *setupMethod) transportHeader(p *packet.Packet, t *header.Transport) string {
+func (s *setupMethod) transportHeader(p *packet.Packet, t *header.Transport, session *rtp.Session, group *multicastGroup) string {
 	serverTransport := header.NewTransport()
 
-	if t.HasParameter("client_port") {
-		var d []interface{} = make([]interface{}, len(t.ClientPort))
+	if group != nil {
+		serverTransport.SetDelivery(header.TransportMulticast)
+	} else {
+		serverTransport.SetDelivery(header.TransportUnicast)
+	}
+
+	serverTransport.SetTransport(header.TransportRTP)
+
+	if t.IsSecure() {
+		serverTransport.SetSecureProfile()
+	} else {
+		serverTransport.SetProfile()
+	}
+
+	if group != nil {
+		serverTransport.SetLowerTransport(header.TransportLowerUDP)
+		serverTransport.AppendParameter("destination", group.address)
+		serverTransport.AppendParameter("port", group.port, group.port+1)
+		serverTransport.AppendParameter("ttl", group.ttl)
+	} else if session.IsInterleaved() {
+		var channels []interface{}
+
+		for _, ch := range session.Interleaved() {
+			channels = append(channels, ch)
+		}
+
+		serverTransport.SetLowerTransport(header.TransportLowerTCP)
+		serverTransport.AppendParameter("interleaved", channels...)
+	} else {
+		if t.HasParameter("client_port") {
+			var d []interface{} = make([]interface{}, len(t.ClientPort))
+
+			for i, n := range t.ClientPort {
+				d[i] = n
+			}
+
+			serverTransport.AppendParameter("client_port", d...)
+		}
 
-		for i, n := range t.ClientPort {
-			d[i] = n
+		if t.Destination != "" {
+			serverTransport.AppendParameter("destination", t.Destination)
 		}
 
-		serverTransport.AppendParameter("client_port", d...)
+		serverTransport.SetLowerTransport(header.TransportLowerUDP)
+		serverTransport.AppendParameter("source", s.source())
+		serverTransport.AppendParameter("server_port", s.ServerPortMin, s.ServerPortMax)
 	}
 
-	serverTransport.SetDelivery(header.TransportUnicast)
-	serverTransport.SetTransport(header.TransportRTP)
-	serverTransport.SetProfile()
-	serverTransport.SetLowerTransport(header.TransportLowerUDP)
-	serverTransport.AppendParameter("server_port", s.ServerPortMin, s.ServerPortMax)
-	serverTransport.AppendParameter("mode", "PLAY")
+	serverTransport.AppendParameter("ssrc", fmt.Sprintf("%08X", session.SSRC()))
+
+	if t.IsRecord() {
+		serverTransport.AppendParameter("mode", "RECORD")
+	} else {
+		serverTransport.AppendParameter("mode", "PLAY")
+	}
 
 	return serverTransport.String()
 }
 
+// destination gives the address unicast media is sent to: the one the
+// client connected from or, if the server allows it, the one asked through
+// the Transport destination parameter. It tells if the destination can be
+// used.
+func (s *setupMethod) destination(t *header.Transport) (string, bool) {
+	remote := hostAddr(s.Conn.RemoteAddr())
+
+	if t.Destination == "" {
+		return remote, true
+	}
+
+	addr := strings.Trim(t.Destination, "[]")
+	ip, _ := rtp.ParseAddr(addr)
+	remoteIP, _ := rtp.ParseAddr(remote)
+
+	if ip == nil {
+		return "", false
+	}
+
+	if ip.Equal(remoteIP) {
+		return remote, true
+	}
+
+	// The media socket is bound to the address the request came in on,
+	// so it can only reach addresses of the same family.
+	localIP, _ := rtp.ParseAddr(hostAddr(s.Conn.LocalAddr()))
+
+	if !s.AllowDestination || (ip.To4() == nil) != (localIP.To4() == nil) {
+		return "", false
+	}
+
+	return addr, true
+}
+
+// source gives the address advertised to the client as the media source.
+func (s *setupMethod) source() string {
+	return s.Conn.advertisedAddr(s.PublicAddr)
+}
+
+// releasePort gives back the server port of a UDP session that couldn't be
+// created.
+func (s *setupMethod) releasePort(options rtp.Setup) {
+	if options.Interleaved == nil {
+		s.AvailablePorts.Release(uint32(options.ServerPort))
+	}
+}
+
+// nextInterleavedChannels gives the first pair of interleaved channels not
+// used by the connection, for clients that don't choose their own. It
+// returns nil if all of them are used.
+func (s *setupMethod) nextInterleavedChannels(replaced *rtp.Session) []int {
+	for ch := 0; ch < 255; ch += 2 {
+		channels := []int{ch, ch + 1}
+
+		if s.Conn.channelsAvailable(channels, replaced) {
+			return channels
+		}
+	}
+
+	return nil
+}
+
+// validChannels tells if interleaved channels are a single channel, or a
+// pair of distinct ones, which fit inside the frame header byte.
+func validChannels(channels []int) bool {
+	if len(channels) == 0 || len(channels) > 2 || (len(channels) == 2 && channels[0] == channels[1]) {
+		return false
+	}
+
+	for _, ch := range channels {
+		if ch < 0 || ch > 255 {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (s *setupMethod) Type() methodType {
 	return methodSetup
 }
@@ -0,0 +1,447 @@
+//
+// Description: Streams pulled from upstream RTSP servers.
+//
+package rtsp
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rsfreitas/go-rtsp/internal/sdp"
+)
+
+const (
+	defaultSourceIdleTimeout = 10 * time.Second
+
+	// Reconnection attempts to an upstream server wait from
+	// sourceBackoffMin to sourceBackoffMax, doubling after each failure.
+	sourceBackoffMin = time.Second
+	sourceBackoffMax = 30 * time.Second
+)
+
+// source is a stream pulled from an upstream RTSP server, such as a camera,
+// whose media is relayed to the local clients playing it. The server only
+// keeps connected to it while it has clients. The lock is never held while
+// talking to the upstream server, so a slow one doesn't stall the server.
+type source struct {
+	path    string
+	url     string
+	options ClientSetup
+	server  *Server
+	stop    chan struct{}
+
+	lock   sync.Mutex
+	closed bool
+	stream *Stream
+	client *Client
+
+	// connecting is closed when the connection being opened to the
+	// upstream server, if any, is finished.
+	connecting chan struct{}
+
+	// description is the upstream session description, used to create the
+	// stream, and tracks holds the local track of every upstream media, or
+	// nil for the ones with unsupported encodings.
+	description *sdp.Setup
+	tracks      []*Track
+
+	// idleSince is when the stream was left without clients.
+	idleSince time.Time
+}
+
+// open gives the source stream, connecting to the upstream server if it
+// isn't connected yet.
+func (src *source) open() (*Stream, error) {
+	src.lock.Lock()
+
+	// Only one connection is opened at a time, the other clients wait for
+	// it to finish.
+	for src.connecting != nil {
+		connecting := src.connecting
+		src.lock.Unlock()
+		<-connecting
+		src.lock.Lock()
+	}
+
+	if src.closed {
+		src.lock.Unlock()
+		return nil, errors.New("source closed")
+	}
+
+	// The stream is kept while reconnecting, so its session description
+	// is still available.
+	if src.stream != nil {
+		stream := src.stream
+		src.lock.Unlock()
+
+		return stream, nil
+	}
+
+	connecting := make(chan struct{})
+	src.connecting = connecting
+	src.lock.Unlock()
+
+	stream, c, tracks, err := src.connect()
+
+	src.lock.Lock()
+	defer src.lock.Unlock()
+
+	src.connecting = nil
+	close(connecting)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if src.closed {
+		src.server.removePublishedStream(stream)
+		go c.Close()
+
+		return nil, errors.New("source closed")
+	}
+
+	src.stream = stream
+	src.description = c.description
+	src.tracks = tracks
+	src.client = c
+	src.idleSince = time.Now()
+
+	go src.watch(c)
+
+	return stream, nil
+}
+
+// connect connects to the upstream server, creating the stream that relays
+// its medias and starting to play them.
+func (src *source) connect() (*Stream, *Client, []*Track, error) {
+	c, err := Dial(src.url, src.options)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if _, err := c.Describe(); err != nil {
+		c.Close()
+		return nil, nil, nil, err
+	}
+
+	stream, tracks, err := newSourceStream(src.path, c.description)
+
+	if err != nil {
+		c.Close()
+		return nil, nil, nil, err
+	}
+
+	if err := src.server.streams.add(stream); err != nil {
+		c.Close()
+		return nil, nil, nil, err
+	}
+
+	if err := src.play(c, tracks); err != nil {
+		c.Close()
+		src.server.removePublishedStream(stream)
+		return nil, nil, nil, err
+	}
+
+	return stream, c, tracks, nil
+}
+
+// play sets up all supported upstream medias, relaying them to their local
+// tracks, and starts receiving them.
+func (src *source) play(c *Client, tracks []*Track) error {
+	for _, ct := range c.tracks {
+		t := tracks[ct.index]
+
+		if t == nil {
+			continue
+		}
+
+		ct.forward = t.relay
+
+		if err := c.Setup(ct); err != nil {
+			return err
+		}
+	}
+
+	return c.Play(nil)
+}
+
+// watch waits for the upstream connection to fail, reconnecting to it if
+// the stream still has clients.
+func (src *source) watch(c *Client) {
+	select {
+	case <-c.done:
+	case <-src.stop:
+		return
+	}
+
+	src.lock.Lock()
+
+	// The connection was closed on purpose
+	if src.client != c {
+		src.lock.Unlock()
+		return
+	}
+
+	src.client = nil
+	src.lock.Unlock()
+
+	c.Close()
+	src.reconnect()
+}
+
+// reconnect connects again to the upstream server, with an increasing
+// interval between attempts. It gives up when the stream has no clients
+// left or the upstream stream has changed its medias, removing it.
+func (src *source) reconnect() {
+	backoff := sourceBackoffMin
+
+	for {
+		select {
+		case <-time.After(backoff):
+		case <-src.stop:
+			return
+		}
+
+		src.lock.Lock()
+
+		if src.closed || src.stream == nil {
+			src.lock.Unlock()
+			return
+		}
+
+		if len(src.stream.sessionIDs()) == 0 {
+			src.drop()
+			src.lock.Unlock()
+			return
+		}
+
+		stream, tracks := src.stream, src.tracks
+		src.lock.Unlock()
+
+		c, err := Dial(src.url, src.options)
+
+		if err == nil {
+			_, err = c.Describe()
+		}
+
+		if err == nil {
+			src.lock.Lock()
+
+			// The source may have been closed meanwhile
+			if src.stream != stream || !src.compatible(c.description) {
+				if src.stream == stream {
+					src.drop()
+				}
+
+				src.lock.Unlock()
+				c.Close()
+				return
+			}
+
+			src.lock.Unlock()
+
+			for _, t := range stream.tracks {
+				t.restartSource()
+			}
+
+			err = src.play(c, tracks)
+		}
+
+		if err == nil {
+			src.lock.Lock()
+
+			if src.stream == stream {
+				src.client = c
+				src.lock.Unlock()
+
+				go src.watch(c)
+				return
+			}
+
+			src.lock.Unlock()
+			c.Close()
+			return
+		}
+
+		if c != nil {
+			c.Close()
+		}
+
+		if backoff *= 2; backoff > sourceBackoffMax {
+			backoff = sourceBackoffMax
+		}
+	}
+}
+
+// compatible tells if an upstream session description has the same medias
+// of the one used to create the stream. It must be called with the lock
+// held.
+func (src *source) compatible(desc *sdp.Setup) bool {
+	if len(desc.Medias) != len(src.description.Medias) {
+		return false
+	}
+
+	for i, m := range desc.Medias {
+		previous := src.description.Medias[i]
+
+		if m.Type != previous.Type || !strings.EqualFold(m.Encoding, previous.Encoding) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkIdle disconnects from the upstream server when the stream has been
+// without clients for longer than timeout.
+func (src *source) checkIdle(now time.Time, timeout time.Duration) {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+
+	if src.client == nil {
+		return
+	}
+
+	if len(src.stream.sessionIDs()) > 0 {
+		src.idleSince = time.Time{}
+		return
+	}
+
+	if src.idleSince.IsZero() {
+		src.idleSince = now
+		return
+	}
+
+	if now.Sub(src.idleSince) >= timeout {
+		src.drop()
+	}
+}
+
+// close disconnects from the upstream server, which is not used anymore.
+func (src *source) close() {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+
+	if src.closed {
+		return
+	}
+
+	src.closed = true
+	close(src.stop)
+	src.drop()
+}
+
+// drop disconnects from the upstream server and removes the stream,
+// closing its client sessions. It must be called with the lock held, so the
+// upstream session is finished in background.
+func (src *source) drop() {
+	if src.client != nil {
+		go src.client.Close()
+		src.client = nil
+	}
+
+	if src.stream != nil {
+		src.server.removePublishedStream(src.stream)
+		src.stream = nil
+		src.description = nil
+		src.tracks = nil
+	}
+}
+
+// newSourceStream creates the stream relaying the medias of an upstream
+// session description. Medias with unsupported encodings are left out, so
+// their tracks are nil.
+func newSourceStream(p string, desc *sdp.Setup) (*Stream, []*Track, error) {
+	s := &Stream{
+		path: p,
+		setup: MediaSetup{
+			ClientHost: "0.0.0.0",
+		},
+		sessions: make(map[string]*session),
+	}
+
+	tracks := make([]*Track, len(desc.Medias))
+
+	for i, m := range desc.Medias {
+		t, err := newAnnouncedTrack(s, m)
+
+		if err != nil {
+			continue
+		}
+
+		t.index = len(s.tracks)
+		s.tracks = append(s.tracks, t)
+		tracks[i] = t
+	}
+
+	if len(s.tracks) == 0 {
+		return nil, nil, errors.New("upstream stream without supported medias")
+	}
+
+	s.session = s.newSDP()
+
+	return s, tracks, nil
+}
+
+// sourceRegistry holds all sources of the server, keyed by their URL path.
+type sourceRegistry struct {
+	lock    sync.RWMutex
+	sources map[string]*source
+}
+
+func (r *sourceRegistry) add(src *source) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.sources[src.path]; ok {
+		return errors.New("source already exists at " + src.path)
+	}
+
+	r.sources[src.path] = src
+
+	return nil
+}
+
+func (r *sourceRegistry) remove(p string) (*source, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	src, ok := r.sources[cleanPath(p)]
+
+	if ok {
+		delete(r.sources, src.path)
+	}
+
+	return src, ok
+}
+
+func (r *sourceRegistry) get(p string) (*source, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	src, ok := r.sources[cleanPath(p)]
+
+	return src, ok
+}
+
+func (r *sourceRegistry) list() []*source {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var sources []*source
+
+	for _, src := range r.sources {
+		sources = append(sources, src)
+	}
+
+	return sources
+}
+
+func newSourceRegistry() *sourceRegistry {
+	return &sourceRegistry{
+		sources: make(map[string]*source),
+	}
+}
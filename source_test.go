@@ -0,0 +1,153 @@
+//
+// Description: Stream source tests.
+//
+package rtsp_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rsfreitas/go-rtsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSource(t *testing.T) {
+	assert := assert.New(t)
+	s, stream, url := startServer(t, rtsp.ServerSetup{UDPPortMin: 47500, UDPPortMax: 47599}, playHandler{})
+	defer s.Close()
+
+	relay, _, _ := startServer(t, rtsp.ServerSetup{UDPPortMin: 47600, UDPPortMax: 47699}, playHandler{})
+	defer relay.Close()
+
+	relayURL := "rtsp://" + relay.Addrs()[0].String() + "/lobby"
+	assert.Nil(relay.AddSource("/lobby", url, rtsp.ClientSetup{UDPPortMin: 47700, UDPPortMax: 47799}))
+
+	frames := make(chan *rtsp.Frame, 64)
+	c, err := rtsp.Dial(relayURL, rtsp.ClientSetup{
+		Transport: rtsp.ClientTransportTCP,
+		Receive: func(f *rtsp.Frame) {
+			select {
+			case frames <- f:
+			default:
+			}
+		},
+	})
+
+	if !assert.Nil(err) {
+		return
+	}
+
+	defer c.Close()
+
+	// The upstream server is only connected when the source is described
+	assert.Len(s.Sessions(), 0)
+
+	tracks, err := c.Describe()
+
+	if !assert.Nil(err) || !assert.Len(tracks, 1) {
+		return
+	}
+
+	assert.Len(s.Sessions(), 1)
+	assert.Nil(c.Setup(tracks[0]))
+	assert.Nil(c.Play(nil))
+
+	if f := receiveFrame(stream.WriteSample, frames); assert.NotNil(f) {
+		assert.True(bytes.Contains(f.Data, testFrame()))
+	}
+}
+
+func TestSourceUnresponsive(t *testing.T) {
+	assert := assert.New(t)
+	s, _, _ := startServer(t, rtsp.ServerSetup{UDPPortMin: 47800, UDPPortMax: 47899}, playHandler{})
+	defer s.Close()
+
+	// An upstream server that accepts connections but never replies
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if !assert.Nil(err) {
+		return
+	}
+
+	defer l.Close()
+
+	go func() {
+		for {
+			c, err := l.Accept()
+
+			if err != nil {
+				return
+			}
+
+			defer c.Close()
+		}
+	}()
+
+	assert.Nil(s.AddSource("/lobby", "rtsp://"+l.Addr().String()+"/cam", rtsp.ClientSetup{
+		Timeout: 2 * time.Second,
+	}))
+
+	c, err := rtsp.Dial("rtsp://"+s.Addrs()[0].String()+"/lobby", rtsp.ClientSetup{})
+
+	if !assert.Nil(err) {
+		return
+	}
+
+	defer c.Close()
+
+	described := make(chan error)
+
+	go func() {
+		_, err := c.Describe()
+		described <- err
+	}()
+
+	// The source isn't locked while waiting for the upstream server
+	time.Sleep(200 * time.Millisecond)
+	start := time.Now()
+	assert.Nil(s.RemoveSource("/lobby"))
+	assert.True(time.Since(start) < 500*time.Millisecond)
+	assert.NotNil(<-described)
+}
+
+func TestSourceBelowStream(t *testing.T) {
+	assert := assert.New(t)
+	s, _, url := startServer(t, rtsp.ServerSetup{UDPPortMin: 47500, UDPPortMax: 47599}, playHandler{})
+	defer s.Close()
+
+	// The relay has a stream at the root path, whose track is /trackID=0
+	relay, err := rtsp.NewServer(rtsp.ServerSetup{
+		ListenAddrs: []string{"127.0.0.1:0"},
+		UDPPortMin:  47600,
+		UDPPortMax:  47699,
+		MediaSetup:  &rtsp.MediaSetup{SPS: testSPS, PPS: testPPS},
+	}, playHandler{})
+
+	if !assert.Nil(err) {
+		return
+	}
+
+	go relay.Start()
+	defer relay.Close()
+
+	assert.NotNil(relay.AddSource("/", url, rtsp.ClientSetup{}))
+	assert.NotNil(relay.AddSource("/trackID=0", url, rtsp.ClientSetup{}))
+	assert.Nil(relay.AddSource("/cam", url, rtsp.ClientSetup{UDPPortMin: 47700, UDPPortMax: 47799}))
+
+	c, err := rtsp.Dial("rtsp://"+relay.Addrs()[0].String()+"/cam", rtsp.ClientSetup{})
+
+	if !assert.Nil(err) {
+		return
+	}
+
+	defer c.Close()
+
+	tracks, err := c.Describe()
+	assert.Nil(err)
+	assert.Len(tracks, 1)
+
+	// The upstream server was connected
+	assert.Len(s.Sessions(), 1)
+}
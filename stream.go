@@ -0,0 +1,366 @@
+//
+// Description: Media streams available to clients.
+//
+package rtsp
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/rsfreitas/go-rtsp/internal/rtp"
+	"github.com/rsfreitas/go-rtsp/internal/sdp"
+	"github.com/rsfreitas/go-rtsp/internal/srtp"
+)
+
+// Stream is a media stream available to clients through a URL path, such
+// as rtsp://host/cam1. It has a video track and, optionally, an audio one.
+type Stream struct {
+	path     string
+	setup    MediaSetup
+	session  *sdp.Session
+	lock     sync.RWMutex
+	sessions map[string]*session
+	tracks   []*Track
+
+	// publisher is the connection of the client which announced the
+	// stream, or nil for streams added by the application.
+	publisher *conn
+}
+
+// Path gives the URL path of the stream.
+func (s *Stream) Path() string {
+	return s.path
+}
+
+// Tracks gives all stream tracks.
+func (s *Stream) Tracks() []*Track {
+	return s.tracks
+}
+
+// VideoTrack gives the stream video track.
+func (s *Stream) VideoTrack() *Track {
+	return s.trackByKind(trackVideo)
+}
+
+// AudioTrack gives the stream audio track, or nil if the stream doesn't
+// have audio.
+func (s *Stream) AudioTrack() *Track {
+	return s.trackByKind(trackAudio)
+}
+
+func (s *Stream) trackByKind(kind string) *Track {
+	for _, t := range s.tracks {
+		if t.kind == kind {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// WriteSample sends a video sample, such as an encoded video frame, to
+// every client currently playing the stream. The timestamp is the sample
+// presentation time, relative to the beginning of the stream.
+func (s *Stream) WriteSample(timestamp time.Duration, data []byte) error {
+	t := s.VideoTrack()
+
+	if t == nil {
+		return errors.New("stream without video")
+	}
+
+	return t.WriteSample(timestamp, data)
+}
+
+// addSession attaches a client session to the stream.
+func (s *Stream) addSession(sess *session) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.sessions[sess.id] = sess
+}
+
+// removeSession detaches a client session from the stream.
+func (s *Stream) removeSession(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.sessions, id)
+}
+
+// track finds the track a control refers to. An empty control refers to
+// the whole stream, which is only accepted as a track if the stream has a
+// single one.
+func (s *Stream) track(control string) (*Track, bool) {
+	if control == "" {
+		if len(s.tracks) == 1 {
+			return s.tracks[0], true
+		}
+
+		return nil, false
+	}
+
+	for _, t := range s.tracks {
+		if t.control == control {
+			return t, true
+		}
+	}
+
+	return nil, false
+}
+
+// hasControl checks if control is a valid track control of the stream. An
+// empty control refers to the whole stream.
+func (s *Stream) hasControl(control string) bool {
+	if control == "" {
+		return true
+	}
+
+	_, ok := s.track(control)
+
+	return ok
+}
+
+// sessionIDs gives the identification of all client sessions attached to
+// the stream.
+func (s *Stream) sessionIDs() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var ids []string
+
+	for id := range s.sessions {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// sdp gives the stream session description.
+func (s *Stream) sdp() *sdp.Session {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.session
+}
+
+// describe gives the stream session description as sent to a client which
+// connected to the server through the local address origin, so its
+// addresses are of the same family. If keys are used, they're the SRTP
+// master keys of each track, announced to the client.
+func (s *Stream) describe(origin string, keys [][]byte) *sdp.Session {
+	setup := s.sdp().Setup
+	setup.Origin = origin
+
+	if keys != nil {
+		setup.Medias = append([]sdp.MediaSetup(nil), setup.Medias...)
+
+		for i, key := range keys {
+			setup.Medias[i].Crypto = []string{srtp.CryptoAttribute(1, key)}
+		}
+	}
+
+	// Unicast streams are usually described with an unspecified address,
+	// which must follow the connection family.
+	ip := net.ParseIP(setup.ClientHost)
+	local := net.ParseIP(origin)
+
+	if ip != nil && local != nil && ip.IsUnspecified() {
+		if local.To4() != nil {
+			setup.ClientHost = net.IPv4zero.String()
+		} else {
+			setup.ClientHost = net.IPv6unspecified.String()
+		}
+	}
+
+	return sdp.NewSession(setup)
+}
+
+// newSDP creates the stream session description, with all of its tracks.
+func (s *Stream) newSDP() *sdp.Session {
+	var medias []sdp.MediaSetup
+
+	setup := sdp.Setup{
+		ClientHost: s.setup.ClientHost,
+		Duration:   s.setup.Duration,
+	}
+
+	// Multicast streams are described with their group, where each track
+	// is found at its own port.
+	if m := s.setup.Multicast; m != nil {
+		setup.ClientHost = m.Group
+		setup.TTL = m.TTL
+	}
+
+	for _, t := range s.tracks {
+		media := t.media()
+
+		if t.multicast != nil {
+			media.Port = t.multicast.port
+		}
+
+		medias = append(medias, media)
+	}
+
+	setup.Medias = medias
+
+	return sdp.NewSession(setup)
+}
+
+// newAnnouncedStream creates a stream from the session description
+// announced by a client which is going to publish it.
+func newAnnouncedStream(p string, desc *sdp.Setup, publisher *conn) (*Stream, error) {
+	s := &Stream{
+		path: p,
+		setup: MediaSetup{
+			ClientHost: "0.0.0.0",
+		},
+		sessions:  make(map[string]*session),
+		publisher: publisher,
+	}
+
+	for _, m := range desc.Medias {
+		t, err := newAnnouncedTrack(s, m)
+
+		if err != nil {
+			return nil, err
+		}
+
+		t.index = len(s.tracks)
+		s.tracks = append(s.tracks, t)
+	}
+
+	if len(s.tracks) == 0 {
+		return nil, errors.New("session description without medias")
+	}
+
+	s.session = s.newSDP()
+
+	return s, nil
+}
+
+func newStream(p string, options *MediaSetup) *Stream {
+	s := &Stream{
+		path:     p,
+		setup:    *options,
+		sessions: make(map[string]*session),
+	}
+
+	maxPayloadSize := 0
+
+	if options.MTU > 0 {
+		maxPayloadSize = options.MTU - rtp.HeaderLength
+	}
+
+	s.tracks = append(s.tracks, newVideoTrack(s, options, maxPayloadSize))
+
+	if options.Audio != nil {
+		s.tracks = append(s.tracks, newAudioTrack(s, options.Audio, maxPayloadSize))
+	}
+
+	if options.Multicast != nil {
+		m := options.Multicast.withDefaults()
+		s.setup.Multicast = &m
+
+		for _, t := range s.tracks {
+			t.multicast = newMulticastGroup(t, &m)
+		}
+	}
+
+	s.session = s.newSDP()
+
+	return s
+}
+
+// streamRegistry holds all streams available in the server, keyed by their
+// URL path.
+type streamRegistry struct {
+	lock    sync.RWMutex
+	streams map[string]*Stream
+}
+
+// add registers a new stream at a URL path.
+func (r *streamRegistry) add(s *Stream) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.streams[s.path]; ok {
+		return errors.New("a stream already exists at " + s.path)
+	}
+
+	// Clients of a multicast group would receive the packets of every
+	// stream sent to it.
+	for _, o := range r.streams {
+		if multicastOverlaps(s, o) {
+			return errors.New("multicast group already used by the stream at " + o.path)
+		}
+	}
+
+	r.streams[s.path] = s
+
+	return nil
+}
+
+// remove unregisters the stream from a URL path, returning it.
+func (r *streamRegistry) remove(p string) (*Stream, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	p = cleanPath(p)
+	s, ok := r.streams[p]
+
+	if ok {
+		delete(r.streams, p)
+	}
+
+	return s, ok
+}
+
+// get gives the stream registered exactly at a URL path.
+func (r *streamRegistry) get(p string) (*Stream, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	s, ok := r.streams[cleanPath(p)]
+
+	return s, ok
+}
+
+// lookup finds the stream a request URL refers to. Since the URL may point
+// to a stream track (rtsp://host/cam1/trackID=0), the last URL path segment
+// is also tried as a track control, which is returned with the stream.
+func (r *streamRegistry) lookup(u *url.URL) (*Stream, string) {
+	if u == nil {
+		return nil, ""
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	p := cleanPath(u.Path)
+
+	if s, ok := r.streams[p]; ok {
+		return s, ""
+	}
+
+	if s, ok := r.streams[path.Dir(p)]; ok {
+		return s, path.Base(p)
+	}
+
+	return nil, ""
+}
+
+func newStreamRegistry() *streamRegistry {
+	return &streamRegistry{
+		streams: make(map[string]*Stream),
+	}
+}
+
+// cleanPath normalizes a stream URL path, so /cam1, cam1 and /cam1/ are
+// the same stream.
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
@@ -10,18 +10,19 @@ import (
 
 	"github.com/rsfreitas/go-rtsp/internal/adt"
 	"github.com/rsfreitas/go-rtsp/internal/packet"
-	"github.com/rsfreitas/go-rtsp/internal/rtp"
 )
 
 type teardownMethod struct {
 	clientHandler ClientTeardown
 
-	ActiveSessions map[string]*rtp.Session
+	ActiveSessions *sessionManager
 	AvailablePorts *adt.RangeBox
+	Streams        *streamRegistry
+	CloseSession   func(*session)
 }
 
 func (t *teardownMethod) Verify(p *packet.Packet, handler interface{}) error {
-	if m, ok := handler.(interface{ Teardown() }); ok {
+	if m, ok := handler.(ClientTeardown); ok {
 		t.clientHandler = m
 		//	} else {
 		//		return &methodError{"client method not implemented", true}
@@ -30,39 +31,49 @@ func (t *teardownMethod) Verify(p *packet.Packet, handler interface{}) error {
 	return nil
 }
 
-func (t *teardownMethod) Handle(p *packet.Packet) {
-	var (
-		clientSession string
-		session       *rtp.Session
-	)
+func (t *teardownMethod) Handle(p *packet.Packet, r *Request) {
+	w := newResponse(p)
+	stream, control := t.Streams.lookup(p.Request.URL)
 
-	if t.clientHandler != nil {
-		t.clientHandler.Teardown()
+	if stream == nil || !stream.hasControl(control) {
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
 
-	if field, ok := p.Request.Headers["Session"]; ok {
-		if t.ActiveSessions == nil {
-			p.Response.StatusCode = StatusAggregateOperationNotAllowed
-			p.Response.StatusText = StatusText(p.Response.StatusCode)
-			return
-		}
+	sess, ok := t.ActiveSessions.get(r.Session)
+
+	if !ok || sess.stream != stream {
+		w.WriteHeader(StatusSessionNotFound)
+		return
+	}
+
+	// A track URL only finishes that track, while the session is kept
+	// until all of its tracks are gone.
+	track, ok := stream.track(control)
+	single := ok && control != ""
 
-		clientSession = field[0]
-		session, ok = t.ActiveSessions[clientSession]
+	if single && !sess.hasTrack(track.index) {
+		w.WriteHeader(StatusSessionNotFound)
+		return
+	}
+
+	if t.clientHandler != nil {
+		t.clientHandler.Teardown(w, r)
 
-		if !ok {
-			p.Response.StatusCode = StatusSessionNotFound
-			p.Response.StatusText = StatusText(p.Response.StatusCode)
+		if w.failed() {
 			return
 		}
 	}
 
-	port := session.Port()
-	t.AvailablePorts.Release(uint32(port))
-	session.Close()
+	if single {
+		sess.closeTrack(track.index, t.AvailablePorts)
+	}
+
+	if control == "" || sess.trackCount() == 0 {
+		t.CloseSession(sess)
+	}
 
-	p.Response.StatusCode = http.StatusOK
-	p.Response.StatusText = http.StatusText(http.StatusOK)
+	w.finish()
 }
 
 func (t *teardownMethod) Type() methodType {
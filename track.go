@@ -0,0 +1,453 @@
+//
+// Description: Media tracks of a stream.
+//
+package rtsp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rsfreitas/go-rtsp/internal/rtp"
+	"github.com/rsfreitas/go-rtsp/internal/sdp"
+)
+
+const (
+	trackVideo = "video"
+	trackAudio = "audio"
+
+	defaultPayloadType = 96
+	defaultClockRate   = 90000
+
+	h263PayloadType = 99
+	aacPayloadType  = 97
+	opusPayloadType = 111
+
+	defaultAudioSampleRate = 44100
+	defaultAudioChannels   = 2
+)
+
+// Track is a single media (video or audio) of a stream. Clients setup each
+// track separately, using its control URL (rtsp://host/cam1/trackID=0).
+type Track struct {
+	stream      *Stream
+	index       int
+	kind        string
+	control     string
+	payloadType uint8
+	clockRate   int
+	packetizer  rtp.Packetizer
+
+	// position is the timestamp of the last sample written, accessed
+	// atomically.
+	position int64
+
+	// Media relayed from the stream source. The depacketizer is only used
+	// to find the video parameter sets not announced by the source.
+	ingestLock   sync.Mutex
+	depacketizer rtp.Depacketizer
+	ingest       ingestClock
+
+	// Video parameter sets, as announced to clients
+	video MediaSetup
+	vps   []byte
+	sps   []byte
+	pps   []byte
+
+	audio AudioSetup
+
+	// multicast delivers the track to the stream multicast group, if the
+	// stream has one.
+	multicast *multicastGroup
+}
+
+// Control gives the track control URL, relative to the stream one.
+func (t *Track) Control() string {
+	return t.control
+}
+
+// WriteSample sends a media sample, such as an encoded video frame or an
+// audio frame, to every client currently playing the track. The
+// timestamp is the sample presentation time, relative to the beginning of
+// the stream.
+func (t *Track) WriteSample(timestamp time.Duration, data []byte) error {
+	sample, err := t.packetize(timestamp, data)
+
+	if err != nil {
+		return err
+	}
+
+	t.stream.lock.RLock()
+	defer t.stream.lock.RUnlock()
+
+	for _, session := range t.stream.sessions {
+		if r := session.track(t.index); r != nil {
+			sample.writeTo(r)
+		}
+	}
+
+	if r := t.multicastSession(); r != nil {
+		sample.writeTo(r)
+	}
+
+	return nil
+}
+
+// packetizedSample is a media sample split into RTP payloads, which can be
+// sent through any number of RTP sessions.
+type packetizedSample struct {
+	rtpTime   uint32
+	payloads  [][]byte
+	durations []uint32
+}
+
+// writeTo sends the sample through a RTP session.
+func (s *packetizedSample) writeTo(r *rtp.Session) {
+	if s.durations != nil {
+		r.WriteAudioPayloads(s.rtpTime, s.payloads, s.durations)
+	} else {
+		r.WritePayloads(s.rtpTime, s.payloads)
+	}
+}
+
+// packetize splits a media sample into RTP payloads, keeping its timestamp
+// as the track position.
+func (t *Track) packetize(timestamp time.Duration, data []byte) (*packetizedSample, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty sample")
+	}
+
+	sample := &packetizedSample{
+		payloads: t.packetizer.Packetize(data),
+		rtpTime:  t.rtpTime(timestamp),
+	}
+
+	t.updateParameterSets()
+	atomic.StoreInt64(&t.position, int64(timestamp))
+
+	if a, ok := t.packetizer.(rtp.AudioPacketizer); ok {
+		for _, payload := range sample.payloads {
+			sample.durations = append(sample.durations, a.Duration(payload))
+		}
+	}
+
+	return sample, nil
+}
+
+// rtpTime converts a sample timestamp to the track clock rate, rounding to
+// the nearest clock unit.
+func (t *Track) rtpTime(timestamp time.Duration) uint32 {
+	return uint32((int64(timestamp)*int64(t.clockRate) + int64(time.Second)/2) / int64(time.Second))
+}
+
+// currentPosition gives the timestamp of the last sample written.
+func (t *Track) currentPosition() time.Duration {
+	return time.Duration(atomic.LoadInt64(&t.position))
+}
+
+// updateParameterSets updates the stream session description when the
+// parameter sets were not informed when creating the stream and they were
+// found (or changed) inside the written samples.
+func (t *Track) updateParameterSets() {
+	var vps, sps, pps []byte
+
+	switch h := t.packetizer.(type) {
+	case *rtp.H264Packetizer:
+		if t.video.SPS != nil && t.video.PPS != nil {
+			return
+		}
+
+		sps, pps = h.ParameterSets()
+
+	case *rtp.H265Packetizer:
+		if t.video.VPS != nil && t.video.SPS != nil && t.video.PPS != nil {
+			return
+		}
+
+		vps, sps, pps = h.ParameterSets()
+
+		if vps == nil {
+			return
+		}
+
+	default:
+		return
+	}
+
+	if sps == nil || pps == nil {
+		return
+	}
+
+	t.stream.lock.Lock()
+	defer t.stream.lock.Unlock()
+
+	if bytes.Equal(vps, t.vps) && bytes.Equal(sps, t.sps) && bytes.Equal(pps, t.pps) {
+		return
+	}
+
+	t.vps, t.sps, t.pps = vps, sps, pps
+	t.stream.session = t.stream.newSDP()
+}
+
+// hasParameterSets checks if the track already knows its SPS and PPS. They
+// are guarded by the stream lock, since updateParameterSets replaces them.
+func (t *Track) hasParameterSets() bool {
+	t.stream.lock.RLock()
+	defer t.stream.lock.RUnlock()
+
+	return t.sps != nil && t.pps != nil
+}
+
+// media gives the track description to be used inside the stream SDP.
+func (t *Track) media() sdp.MediaSetup {
+	media := sdp.MediaSetup{
+		Type:        t.kind,
+		PayloadType: int(t.payloadType),
+		Control:     t.control,
+	}
+
+	if t.kind == trackAudio {
+		switch t.audio.Codec {
+		case AudioAACLATM:
+			media.Encoding = fmt.Sprintf("MP4A-LATM/%d/%d", t.clockRate, t.audio.Channels)
+			media.Fmtp = rtp.LATMFormatParameters(rtp.AACObjectTypeLC,
+				t.audio.SampleRate, t.audio.Channels)
+
+		case AudioPCMU:
+			media.Encoding = fmt.Sprintf("PCMU/%d", t.clockRate)
+
+		case AudioPCMA:
+			media.Encoding = fmt.Sprintf("PCMA/%d", t.clockRate)
+
+		case AudioG722:
+			media.Encoding = fmt.Sprintf("G722/%d", t.clockRate)
+
+		case AudioOpus:
+			// Opus is always announced as stereo, the real channels are
+			// informed through fmtp (RFC 7587 section 7).
+			media.Encoding = fmt.Sprintf("opus/%d/2", t.clockRate)
+			media.Fmtp = rtp.OpusFormatParameters(t.audio.Channels)
+
+		default:
+			media.Encoding = fmt.Sprintf("mpeg4-generic/%d/%d", t.clockRate, t.audio.Channels)
+			media.Fmtp = rtp.AACFormatParameters(t.audio.Config)
+		}
+
+		return media
+	}
+
+	media.Port = t.video.Port
+
+	switch t.video.Codec {
+	case VideoH263:
+		media.Encoding = fmt.Sprintf("h263-1998/%d", t.clockRate)
+
+	case VideoH265:
+		media.Encoding = fmt.Sprintf("H265/%d", t.clockRate)
+		media.Fmtp = rtp.H265FormatParameters(t.vps, t.sps, t.pps)
+
+	default:
+		media.Encoding = fmt.Sprintf("H264/%d", t.clockRate)
+		media.Fmtp = rtp.H264FormatParameters(t.sps, t.pps)
+	}
+
+	return media
+}
+
+// newAnnouncedTrack creates a track from a media announced by a client
+// publishing a stream, keeping its payload type and control URL.
+func newAnnouncedTrack(s *Stream, m sdp.MediaSetup) (*Track, error) {
+	var (
+		t        *Track
+		encoding = strings.Split(m.Encoding, "/")
+		name     = strings.ToUpper(encoding[0])
+		rate     int
+		channels = 1
+	)
+
+	if len(encoding) > 1 {
+		rate, _ = strconv.Atoi(encoding[1])
+	}
+
+	if len(encoding) > 2 {
+		channels, _ = strconv.Atoi(encoding[2])
+	}
+
+	// Static payload types may be announced without rtpmap
+	if m.Encoding == "" {
+		switch m.PayloadType {
+		case rtp.PCMUPayloadType:
+			name = "PCMU"
+
+		case rtp.PCMAPayloadType:
+			name = "PCMA"
+
+		case rtp.G722PayloadType:
+			name = "G722"
+		}
+	}
+
+	switch {
+	case m.Type == trackVideo && name == "H264":
+		options := &MediaSetup{Codec: VideoH264}
+		options.SPS, options.PPS = rtp.H264ParameterSets(m.Fmtp)
+		t = newVideoTrack(s, options, 0)
+		t.depacketizer = rtp.NewH264Depacketizer()
+
+	case m.Type == trackVideo && (name == "H265" || name == "HEVC"):
+		options := &MediaSetup{Codec: VideoH265}
+		options.VPS, options.SPS, options.PPS = rtp.H265ParameterSets(m.Fmtp)
+		t = newVideoTrack(s, options, 0)
+		t.depacketizer = rtp.NewH265Depacketizer()
+
+	case m.Type == trackVideo && name == "H263-1998":
+		t = newVideoTrack(s, &MediaSetup{Codec: VideoH263}, 0)
+
+	case m.Type == trackAudio && name == "MPEG4-GENERIC":
+		config := rtp.AACConfig(m.Fmtp)
+		_, sampleRate, configChannels, err := rtp.ParseAudioSpecificConfig(config)
+
+		if err != nil {
+			return nil, err
+		}
+
+		t = newAudioTrack(s, &AudioSetup{
+			Codec:      AudioAAC,
+			SampleRate: sampleRate,
+			Channels:   configChannels,
+			Config:     config,
+		}, 0)
+
+	case m.Type == trackAudio && name == "MP4A-LATM":
+		t = newAudioTrack(s, &AudioSetup{
+			Codec:      AudioAACLATM,
+			SampleRate: rate,
+			Channels:   channels,
+		}, 0)
+
+	case m.Type == trackAudio && (name == "PCMU" || name == "PCMA" || name == "G722" || name == "OPUS"):
+		codecs := map[string]AudioCodec{
+			"PCMU": AudioPCMU,
+			"PCMA": AudioPCMA,
+			"G722": AudioG722,
+			"OPUS": AudioOpus,
+		}
+
+		t = newAudioTrack(s, &AudioSetup{
+			Codec:    codecs[name],
+			Channels: channels,
+		}, 0)
+
+	default:
+		return nil, fmt.Errorf("unsupported %s encoding %q", m.Type, m.Encoding)
+	}
+
+	t.payloadType = uint8(m.PayloadType)
+
+	if m.Control != "" {
+		t.control = path.Base(m.Control)
+	}
+
+	return t, nil
+}
+
+func newTrack(s *Stream, kind string) *Track {
+	index := len(s.tracks)
+
+	return &Track{
+		stream:  s,
+		index:   index,
+		kind:    kind,
+		control: fmt.Sprintf("trackID=%d", index),
+	}
+}
+
+func newVideoTrack(s *Stream, options *MediaSetup, maxPayloadSize int) *Track {
+	t := newTrack(s, trackVideo)
+	t.video = *options
+	t.clockRate = defaultClockRate
+
+	switch options.Codec {
+	case VideoH263:
+		t.payloadType = h263PayloadType
+		t.packetizer = rtp.NewRawPacketizer()
+
+	case VideoH265:
+		t.payloadType = defaultPayloadType
+		t.packetizer = rtp.NewH265Packetizer(maxPayloadSize)
+
+	default:
+		t.payloadType = defaultPayloadType
+		t.packetizer = rtp.NewH264Packetizer(maxPayloadSize)
+	}
+
+	t.vps, t.sps, t.pps = options.VPS, options.SPS, options.PPS
+
+	return t
+}
+
+func newAudioTrack(s *Stream, options *AudioSetup, maxPayloadSize int) *Track {
+	t := newTrack(s, trackAudio)
+	t.audio = *options
+
+	switch t.audio.Codec {
+	case AudioPCMU, AudioPCMA, AudioG722:
+		t.audio.Channels = 1
+		t.audio.SampleRate = rtp.PCMClockRate
+		t.clockRate = rtp.PCMClockRate
+		t.packetizer = rtp.NewPCMPacketizer(maxPayloadSize)
+
+		switch t.audio.Codec {
+		case AudioPCMU:
+			t.payloadType = rtp.PCMUPayloadType
+
+		case AudioPCMA:
+			t.payloadType = rtp.PCMAPayloadType
+
+		case AudioG722:
+			t.payloadType = rtp.G722PayloadType
+			t.audio.SampleRate = 16000
+		}
+
+	case AudioOpus:
+		if t.audio.Channels == 0 {
+			t.audio.Channels = defaultAudioChannels
+		}
+
+		t.clockRate = rtp.OpusClockRate
+		t.packetizer = rtp.NewOpusPacketizer()
+		t.payloadType = opusPayloadType
+
+	default:
+		if t.audio.SampleRate == 0 {
+			t.audio.SampleRate = defaultAudioSampleRate
+		}
+
+		if t.audio.Channels == 0 {
+			t.audio.Channels = defaultAudioChannels
+		}
+
+		if t.audio.Config == nil {
+			t.audio.Config = rtp.AudioSpecificConfig(rtp.AACObjectTypeLC,
+				t.audio.SampleRate, t.audio.Channels)
+		}
+
+		// AAC RTP timestamps use the sampling rate as clock
+		t.clockRate = t.audio.SampleRate
+		t.payloadType = aacPayloadType
+
+		if t.audio.Codec == AudioAACLATM {
+			t.packetizer = rtp.NewLATMPacketizer(maxPayloadSize)
+		} else {
+			t.packetizer = rtp.NewAACPacketizer(maxPayloadSize)
+		}
+	}
+
+	return t
+}